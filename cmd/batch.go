@@ -0,0 +1,269 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ossign/ossign/pkg/ossign"
+	"github.com/ossign/ossign/pkg/sigerr"
+	"github.com/spf13/cobra"
+)
+
+var signCmd = &cobra.Command{
+	Use:   "sign [files or patterns...]",
+	Short: "Sign many files and glob patterns in one invocation",
+	Args:  cobra.MinimumNArgs(1),
+	Run:   RunBatch,
+}
+
+type BatchResult struct {
+	InputFile  string
+	OutputFile string
+	Duration   time.Duration
+	Err        error
+}
+
+func RunBatch(cmd *cobra.Command, args []string) {
+	if signType, err := cmd.Flags().GetString("sign-type"); err == nil && signType != "" {
+		GlobalConfig.SignatureType = ossign.SignatureType(signType)
+	}
+
+	readSigningFlags(cmd)
+
+	workers, err := cmd.Flags().GetInt("workers")
+	if err != nil {
+		fatal("Error reading workers", sigerr.ConfigError{Err: err})
+	}
+
+	outDir, err := cmd.Flags().GetString("output-dir")
+	if err != nil {
+		fatal("Error reading output directory", sigerr.ConfigError{Err: err})
+	}
+
+	inputs, err := ExpandInputs(args)
+	if err != nil {
+		fatal("Error expanding input files", err)
+	}
+
+	outputs, err := batchOutputs(inputs, outDir)
+	if err != nil {
+		fatal("Error preparing output files", err)
+	}
+
+	ctx := context.Background()
+
+	signer, err := ossign.NewSigner(ctx, &GlobalConfig)
+	if err != nil {
+		fatal("Error getting signer", err)
+	}
+
+	results := SignBatch(signer, inputs, outputs, GlobalConfig.SignatureType, workers, ctx)
+	closeSigner(signer)
+
+	failed := 0
+	exitCode := ExitOK
+	for _, res := range results {
+		if res.Err != nil {
+			failed++
+			fmt.Printf("FAIL  %s: %v\n", res.InputFile, res.Err)
+
+			// report the failure type when all files failed the same way
+			if code := ExitCode(res.Err); exitCode == ExitOK {
+				exitCode = code
+			} else if exitCode != code {
+				exitCode = ExitFailure
+			}
+		} else {
+			fmt.Printf("OK    %s -> %s (%s)\n", res.InputFile, res.OutputFile, res.Duration.Round(time.Millisecond))
+		}
+	}
+
+	if failed > 0 {
+		log.Printf("%d of %d files failed to sign", failed, len(results))
+		os.Exit(exitCode)
+	}
+
+	log.Printf("Successfully signed %d files", len(results))
+}
+
+// Sign a list of files concurrently using a shared signer, which only lets
+// keys that declare themselves goroutine-safe sign several files at once.
+// Results are returned in the same order as the inputs.
+func SignBatch(signer *ossign.Signer, inputs, outputs []string, signType ossign.SignatureType, workers int, ctx context.Context) []BatchResult {
+	if workers < 1 {
+		workers = 1
+	}
+
+	results := make([]BatchResult, len(inputs))
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				start := time.Now()
+				err := SignFile(signer, inputs[i], outputs[i], signType, ctx)
+				results[i] = BatchResult{
+					InputFile:  inputs[i],
+					OutputFile: outputs[i],
+					Duration:   time.Since(start),
+					Err:        err,
+				}
+			}
+		}()
+	}
+
+	for i := range inputs {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
+
+// Work out where each signed file goes. Without an output directory files are
+// signed in place.
+func batchOutputs(inputs []string, outDir string) ([]string, error) {
+	if outDir == "" {
+		return inputs, nil
+	}
+
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return nil, err
+	}
+
+	outputs := make([]string, len(inputs))
+	seen := make(map[string]string, len(inputs))
+	for i, input := range inputs {
+		outputs[i] = filepath.Join(outDir, filepath.Base(input))
+		if other, ok := seen[outputs[i]]; ok {
+			return nil, fmt.Errorf("%s and %s would both be written to %s", other, input, outputs[i])
+		}
+		seen[outputs[i]] = input
+	}
+
+	return outputs, nil
+}
+
+// Expand command line arguments into a list of files. Arguments containing
+// glob characters are matched against the filesystem, where "**" matches any
+// number of directories. Each file is only returned once.
+func ExpandInputs(args []string) ([]string, error) {
+	var files []string
+	seen := make(map[string]bool)
+
+	for _, arg := range args {
+		var matches []string
+		switch {
+		case strings.Contains(arg, "**"):
+			found, err := globRecursive(arg)
+			if err != nil {
+				return nil, err
+			}
+			matches = found
+		case strings.ContainsAny(arg, "*?["):
+			found, err := filepath.Glob(arg)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern %q: %w", arg, err)
+			}
+			for _, m := range found {
+				if info, err := os.Stat(m); err == nil && info.Mode().IsRegular() {
+					matches = append(matches, m)
+				}
+			}
+		default:
+			if _, err := os.Stat(arg); err != nil {
+				return nil, err
+			}
+			matches = []string{arg}
+		}
+
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no files match %q", arg)
+		}
+
+		for _, m := range matches {
+			if !seen[m] {
+				seen[m] = true
+				files = append(files, m)
+			}
+		}
+	}
+
+	return files, nil
+}
+
+func globRecursive(pattern string) ([]string, error) {
+	parts := strings.Split(filepath.ToSlash(pattern), "/")
+
+	// walk from the longest prefix without any glob characters
+	var base []string
+	for len(parts) > 0 && !strings.ContainsAny(parts[0], "*?[") {
+		base = append(base, parts[0])
+		parts = parts[1:]
+	}
+	root := "."
+	if len(base) > 0 {
+		root = filepath.FromSlash(strings.Join(base, "/"))
+		if root == "" {
+			root = "/"
+		}
+	}
+
+	var matches []string
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		ok, err := matchSegments(parts, strings.Split(filepath.ToSlash(rel), "/"))
+		if err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+		if ok {
+			matches = append(matches, p)
+		}
+		return nil
+	})
+
+	return matches, err
+}
+
+func matchSegments(pattern, name []string) (bool, error) {
+	if len(pattern) == 0 {
+		return len(name) == 0, nil
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(name); i++ {
+			if ok, err := matchSegments(pattern[1:], name[i:]); ok || err != nil {
+				return ok, err
+			}
+		}
+		return false, nil
+	}
+	if len(name) == 0 {
+		return false, nil
+	}
+	ok, err := path.Match(pattern[0], name[0])
+	if !ok || err != nil {
+		return false, err
+	}
+	return matchSegments(pattern[1:], name[1:])
+}
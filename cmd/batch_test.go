@@ -0,0 +1,53 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandInputs(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.exe", "b.dll", "dist/x64/c.msi", "dist/d.msi", "dist/x64/e.dll"} {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0755))
+		require.NoError(t, os.WriteFile(p, []byte("data"), 0644))
+	}
+
+	files, err := ExpandInputs([]string{
+		filepath.Join(dir, "a.exe"),
+		filepath.Join(dir, "*.dll"),
+		filepath.Join(dir, "dist", "**", "*.msi"),
+		filepath.Join(dir, "a.exe"),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "a.exe"),
+		filepath.Join(dir, "b.dll"),
+		filepath.Join(dir, "dist", "d.msi"),
+		filepath.Join(dir, "dist", "x64", "c.msi"),
+	}, files)
+
+	_, err = ExpandInputs([]string{filepath.Join(dir, "**", "*.sys")})
+	assert.Error(t, err)
+
+	_, err = ExpandInputs([]string{filepath.Join(dir, "missing.exe")})
+	assert.Error(t, err)
+}
+
+func TestBatchOutputs(t *testing.T) {
+	outputs, err := batchOutputs([]string{"a/x.exe", "b/y.exe"}, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a/x.exe", "b/y.exe"}, outputs)
+
+	dir := t.TempDir()
+	outputs, err = batchOutputs([]string{"a/x.exe", "b/y.exe"}, dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "x.exe"), filepath.Join(dir, "y.exe")}, outputs)
+
+	_, err = batchOutputs([]string{"a/x.exe", "b/x.exe"}, dir)
+	assert.Error(t, err)
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/ossign/ossign/pkg/ossign"
+	"github.com/ossign/ossign/pkg/sigerr"
+	"github.com/ossign/ossign/pkg/vfs"
+	rvfs "github.com/sassoftware/relic/v8/lib/vfs"
+	"github.com/spf13/cobra"
+)
+
+var catalogCmd = &cobra.Command{
+	Use:   "catalog",
+	Short: "Create and verify Windows catalog (.cat) files",
+}
+
+var catalogCreateCmd = &cobra.Command{
+	Use:   "create [files or directories...]",
+	Short: "Create a signed catalog of files",
+	Args:  cobra.MinimumNArgs(1),
+	Run:   RunCatalogCreate,
+}
+
+var catalogVerifyCmd = &cobra.Command{
+	Use:   "verify [catalog] [directory]",
+	Short: "Check the files of a directory against a signed catalog",
+	Args:  cobra.ExactArgs(2),
+	Run:   RunCatalogVerify,
+}
+
+func RunCatalogCreate(cmd *cobra.Command, args []string) {
+	readSigningFlags(cmd)
+	GlobalConfig.SignatureType = ossign.CatalogSignature
+
+	out, err := cmd.Flags().GetString("out")
+	if err != nil || out == "" {
+		fatal("Error reading output file", sigerr.ConfigError{Err: fmt.Errorf("--out is required")})
+	}
+	osAttr, err := cmd.Flags().GetString("os-attr")
+	if err != nil {
+		fatal("Error reading OS attribute", sigerr.ConfigError{Err: err})
+	}
+
+	ctx := context.Background()
+
+	signer, err := ossign.NewSigner(ctx, &GlobalConfig)
+	if err != nil {
+		fatal("Error getting signer", err)
+	}
+
+	catalog, err := signer.CreateCatalog(ctx, args, ossign.CatalogOptions{OSAttr: osAttr})
+	closeSigner(signer)
+	if err != nil {
+		fatal("Error creating catalog", err)
+	}
+
+	if err := writeCatalog(out, catalog); err != nil {
+		fatal("Error writing catalog", err)
+	}
+
+	log.Printf("Catalog written to %s", out)
+}
+
+// Write a catalog file, replacing an existing one atomically so that an
+// interrupted write never leaves a broken catalog behind
+func writeCatalog(path string, catalog []byte) error {
+	file := rvfs.New(catalog, path)
+	if _, err := os.Stat(path); err == nil {
+		return vfs.ReplaceFile(file)
+	}
+	return vfs.WriteToFile(file)
+}
+
+func RunCatalogVerify(cmd *cobra.Command, args []string) {
+	catalog, err := os.ReadFile(args[0])
+	if err != nil {
+		fatal("Error reading catalog", err)
+	}
+
+	sig, checks, err := ossign.VerifyCatalog(catalog, args[1])
+	if err != nil {
+		fatal("Error verifying "+args[0], err)
+	}
+
+	fmt.Printf("%s: catalog signature\n", args[0])
+	printSignature(*sig)
+
+	failed := 0
+	for _, check := range checks {
+		if check.Err != nil {
+			failed++
+			fmt.Printf("FAIL  %s: %v\n", check.Name, check.Err)
+		} else {
+			fmt.Printf("OK    %s\n", check.Name)
+		}
+	}
+
+	if failed > 0 {
+		log.Printf("%d of %d files failed the catalog check", failed, len(checks))
+		os.Exit(ExitVerify)
+	}
+
+	log.Printf("Verified %d files against %s OK", len(checks), args[0])
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteCatalog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "driver.cat")
+	require.NoError(t, writeCatalog(path, []byte("first")))
+
+	// an existing catalog is replaced and keeps its mode
+	require.NoError(t, os.Chmod(path, 0600))
+	require.NoError(t, writeCatalog(path, []byte("second")))
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "second", string(content))
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+}
@@ -6,8 +6,12 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"runtime"
 
+	"github.com/ossign/ossign/pkg/ossign"
+	"github.com/ossign/ossign/pkg/sigerr"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
 
@@ -20,7 +24,7 @@ var rootCmd = &cobra.Command{
 
 var cfgFile string
 
-var GlobalConfig SigningConfig
+var GlobalConfig ossign.SigningConfig
 
 func init() {
 	cobra.OnInitialize(initConfig)
@@ -33,8 +37,70 @@ func init() {
 	rootCmd.PersistentFlags().StringVarP(&cfgFile, "config", "c", filepath.Join(homedir, ".ossign/config.yaml"), "config file (default is ~/ossign/config.yaml)")
 
 	// Signing flags
-	rootCmd.Flags().StringVarP((*string)(&GlobalConfig.SignatureType), "sign-type", "t", "", "Type of file to sign (powershell, pecoff, authenticode, dmg, auto)")
+	rootCmd.Flags().StringVarP((*string)(&GlobalConfig.SignatureType), "sign-type", "t", "", "Type of file to sign (powershell, pecoff, msi, cab, appx, appmanifest, dmg, machos, jar, apk, auto)")
 	rootCmd.Flags().StringVarP(&GlobalConfig.OutputFile, "output", "o", "", "Output file for the signed binary (Default: [inputFile]-signed[.ext])")
+	rootCmd.Flags().Bool("in-place", false, "Replace the input file with the signed file atomically")
+	addSigningFlags(rootCmd.Flags())
+
+	// Verify flags
+	verifyCmd.Flags().StringP("sign-type", "t", "", "Type of file to verify (powershell, pecoff, msi, cab, jar, apk, auto)")
+	rootCmd.AddCommand(verifyCmd)
+
+	// Batch signing flags
+	signCmd.Flags().StringP("sign-type", "t", "", "Type of files to sign (powershell, pecoff, msi, cab, appx, appmanifest, dmg, machos, jar, apk, auto)")
+	signCmd.Flags().StringP("output-dir", "d", "", "Directory for the signed files (Default: sign in place)")
+	signCmd.Flags().IntP("workers", "j", runtime.NumCPU(), "Number of files to sign concurrently")
+	addSigningFlags(signCmd.Flags())
+	rootCmd.AddCommand(signCmd)
+
+	// Catalog flags
+	catalogCreateCmd.Flags().StringP("out", "o", "", "Catalog file to write")
+	catalogCreateCmd.Flags().String("os-attr", "", "OSAttr of the catalog members, e.g. 2:10.0")
+	addSigningFlags(catalogCreateCmd.Flags())
+	catalogCmd.AddCommand(catalogCreateCmd, catalogVerifyCmd)
+	rootCmd.AddCommand(catalogCmd)
+
+	// Driver package flags
+	driverCmd.Flags().String("os-attr", "", "OSAttr of the catalog members (Default: from the INF target OS decorations)")
+	addSigningFlags(driverCmd.Flags())
+	rootCmd.AddCommand(driverCmd)
+}
+
+func addSigningFlags(flags *pflag.FlagSet) {
+	flags.Bool("append", false, "Keep an existing PE signature and nest the new one inside it")
+	flags.Bool("no-timestamp", false, "Do not timestamp the signature, e.g. for development builds")
+	flags.StringSlice("timestamp-url", nil, "RFC 3161 timestamp server, may be repeated to try several in order")
+	flags.String("hash-algorithm", "", "Digest algorithm to sign with (sha1, sha256, sha384, sha512) (Default: sha256, or the digest the key requires)")
+	flags.String("description", "", "Publisher description shown by Windows (Default: OSSign)")
+	flags.String("url", "", "Publisher URL shown by Windows (Default: https://ossign.org)")
+	flags.Bool("description-from-version-info", false, "Use the FileDescription or ProductName of PE files as description")
+}
+
+// Override the timestamping, digest algorithm and publisher information from
+// the configuration with flags given on the command line
+func readSigningFlags(cmd *cobra.Command) {
+	if appendSig, err := cmd.Flags().GetBool("append"); err == nil && appendSig {
+		GlobalConfig.AppendSignature = true
+	}
+	if noTimestamp, err := cmd.Flags().GetBool("no-timestamp"); err == nil && noTimestamp {
+		GlobalConfig.NoTimestamp = true
+	}
+	if urls, err := cmd.Flags().GetStringSlice("timestamp-url"); err == nil && len(urls) > 0 {
+		GlobalConfig.TimestampUrl = ""
+		GlobalConfig.TimestampUrls = urls
+	}
+	if hash, err := cmd.Flags().GetString("hash-algorithm"); err == nil && hash != "" {
+		GlobalConfig.HashAlgorithm = hash
+	}
+	if desc, err := cmd.Flags().GetString("description"); err == nil && desc != "" {
+		GlobalConfig.Description = desc
+	}
+	if url, err := cmd.Flags().GetString("url"); err == nil && url != "" {
+		GlobalConfig.URL = url
+	}
+	if fromFile, err := cmd.Flags().GetBool("description-from-version-info"); err == nil && fromFile {
+		GlobalConfig.DescriptionFromVersionInfo = true
+	}
 }
 
 func initConfig() {
@@ -44,7 +110,7 @@ func initConfig() {
 			log.Print("Using config from OSSIGN_CONFIG_BASE64 environment variable")
 			configBytes, err := base64.StdEncoding.DecodeString(os.Getenv("OSSIGN_CONFIG_BASE64"))
 			if err != nil {
-				log.Fatalf("Error decoding OSSIGN_CONFIG_BASE64: %v", err)
+				fatal("Error decoding OSSIGN_CONFIG_BASE64", sigerr.ConfigError{Err: err})
 			}
 			config = configBytes
 		} else {
@@ -56,14 +122,14 @@ func initConfig() {
 		if err := json.Unmarshal([]byte(config), &decoded); err == nil {
 			viper.MergeConfigMap(decoded)
 		} else {
-			log.Fatalf("Error parsing OSSIGN_CONFIG/OSSIGN_CONFIG_BASE64: %v", err)
+			fatal("Error parsing OSSIGN_CONFIG/OSSIGN_CONFIG_BASE64", sigerr.ConfigError{Err: err})
 		}
 
 		log.Println("Using config from OSSIGN_CONFIG environment variable")
 
 		err := viper.Unmarshal(&GlobalConfig)
 		if err != nil {
-			log.Fatal("Unable to decode into struct:", err)
+			fatal("Unable to decode into struct", sigerr.ConfigError{Err: err})
 		}
 
 		return
@@ -96,7 +162,7 @@ func initConfig() {
 	if err := viper.ReadInConfig(); err == nil {
 		err := viper.Unmarshal(&GlobalConfig)
 		if err != nil {
-			log.Fatal("Unable to decode into struct:", err)
+			fatal("Unable to decode into struct", sigerr.ConfigError{Err: err})
 		}
 		log.Println("Using config file:", viper.ConfigFileUsed())
 	} else {
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/ossign/ossign/pkg/ossign"
+	"github.com/ossign/ossign/pkg/sigerr"
+	"github.com/spf13/cobra"
+)
+
+var driverCmd = &cobra.Command{
+	Use:   "driver [package.inf]",
+	Short: "Sign the binaries of a driver package and write its signed catalog",
+	Args:  cobra.ExactArgs(1),
+	Run:   RunDriver,
+}
+
+func RunDriver(cmd *cobra.Command, args []string) {
+	readSigningFlags(cmd)
+	GlobalConfig.SignatureType = ossign.CatalogSignature
+
+	osAttr, err := cmd.Flags().GetString("os-attr")
+	if err != nil {
+		fatal("Error reading OS attribute", sigerr.ConfigError{Err: err})
+	}
+
+	pkg, err := ossign.ParseInf(args[0])
+	if err != nil {
+		fatal("Error reading driver package", err)
+	}
+	if osAttr == "" {
+		osAttr = pkg.OSAttr
+	}
+
+	ctx := context.Background()
+
+	signer, err := ossign.NewSigner(ctx, &GlobalConfig)
+	if err != nil {
+		fatal("Error getting signer", err)
+	}
+
+	// the binaries are signed first, but their catalog digests leave out the
+	// signature anyway
+	for _, file := range pkg.Binaries {
+		if err := SignFile(signer, file, file, ossign.PecoffSignature, ctx); err != nil {
+			closeSigner(signer)
+			fatal("Error signing "+file, err)
+		}
+		fmt.Printf("OK    %s\n", file)
+	}
+
+	files := append([]string{pkg.Inf}, pkg.Files...)
+	catalog, err := signer.CreateCatalog(ctx, files, ossign.CatalogOptions{OSAttr: osAttr})
+	closeSigner(signer)
+	if err != nil {
+		fatal("Error creating catalog", err)
+	}
+	for _, path := range pkg.Catalogs {
+		if err := writeCatalog(path, catalog); err != nil {
+			fatal("Error writing catalog", err)
+		}
+		fmt.Printf("OK    %s (%s)\n", path, osAttr)
+	}
+
+	log.Printf("Signed driver package %s", args[0])
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"os"
+
+	"github.com/ossign/ossign/pkg/sigerr"
+)
+
+// Exit codes of the CLI. Pipelines branch on these, so existing values must
+// never be renumbered.
+const (
+	ExitOK            = 0
+	ExitFailure       = 1 // any error not covered below, e.g. reading or writing files
+	ExitConfig        = 2 // invalid configuration or command line
+	ExitCredential    = 3 // credentials could not be created or were rejected
+	ExitRemoteSigning = 4 // the remote key service failed
+	ExitFormat        = 5 // the file type is unsupported or the file is malformed
+	ExitTimestamp     = 6 // no timestamp could be obtained
+	ExitVerify        = 7 // a signature is missing or does not verify
+)
+
+// Map an error to the exit code for its type
+func ExitCode(err error) int {
+	switch {
+	case err == nil:
+		return ExitOK
+	case errors.As(err, &sigerr.CredentialError{}):
+		return ExitCredential
+	case errors.As(err, &sigerr.TimestampError{}):
+		return ExitTimestamp
+	case errors.As(err, &sigerr.RemoteSigningError{}):
+		return ExitRemoteSigning
+	case errors.As(err, &sigerr.ConfigError{}):
+		return ExitConfig
+	case errors.As(err, &sigerr.FormatError{}):
+		return ExitFormat
+	case errors.As(err, &sigerr.VerifyError{}):
+		return ExitVerify
+	default:
+		return ExitFailure
+	}
+}
+
+// Log an error and exit with the code for its type
+func fatal(msg string, err error) {
+	log.Printf("%s: %v", msg, err)
+	os.Exit(ExitCode(err))
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/ossign/ossign/pkg/sigerr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExitCode(t *testing.T) {
+	base := errors.New("boom")
+	assert.Equal(t, ExitOK, ExitCode(nil))
+	assert.Equal(t, ExitFailure, ExitCode(base))
+	assert.Equal(t, ExitConfig, ExitCode(sigerr.ConfigError{Err: base}))
+	assert.Equal(t, ExitCredential, ExitCode(sigerr.CredentialError{Err: base}))
+	assert.Equal(t, ExitRemoteSigning, ExitCode(sigerr.RemoteSigningError{Err: base}))
+	assert.Equal(t, ExitFormat, ExitCode(sigerr.FormatError{Err: base}))
+	assert.Equal(t, ExitTimestamp, ExitCode(sigerr.TimestampError{Err: base}))
+	assert.Equal(t, ExitVerify, ExitCode(sigerr.VerifyError{Err: base}))
+
+	// wrapping by the signing functions keeps the type
+	wrapped := fmt.Errorf("Error signing file: %w", sigerr.TimestampError{Err: base})
+	assert.Equal(t, ExitTimestamp, ExitCode(wrapped))
+}
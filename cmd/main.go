@@ -2,96 +2,116 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"path/filepath"
 	"strings"
 
+	"github.com/ossign/ossign/pkg/ossign"
+	"github.com/ossign/ossign/pkg/sigerr"
 	"github.com/ossign/ossign/pkg/vfs"
-	"github.com/sassoftware/relic/v8/config"
-	"github.com/sassoftware/relic/v8/lib/certloader"
-	"github.com/sassoftware/relic/v8/lib/pkcs9/tsclient"
 	rvfs "github.com/sassoftware/relic/v8/lib/vfs"
 	"github.com/spf13/cobra"
 )
 
 func main() {
 	if err := rootCmd.Execute(); err != nil {
-		log.Fatalf("Error executing command: %v", err)
+		fatal("Error executing command", sigerr.ConfigError{Err: err})
 	}
 }
 
-var MapTypeToFunc = map[SignatureType]func(*rvfs.File, *certloader.Certificate, string, *rvfs.File, context.Context) error{
-	"powershell":  SignPowershell,
-	"pecoff":      SignPecoff,
-	"msi":         SignMsi,
-	"appx":        SignAppx,
-	"appmanifest": SignAppmanifest,
-	"dmg":         SignDmg,
-	"machos":      SignMachos,
-}
-
 func Run(cmd *cobra.Command, args []string) {
 	if len(args) == 1 {
 		GlobalConfig.InputFile = args[0]
 	}
 
 	if signType, err := cmd.Flags().GetString("sign-type"); err == nil && signType != "" {
-		GlobalConfig.SignatureType = SignatureType(signType)
+		GlobalConfig.SignatureType = ossign.SignatureType(signType)
 	}
 
 	if outFile, err := cmd.Flags().GetString("output"); err == nil && outFile != "" {
 		GlobalConfig.OutputFile = outFile
 	}
 
+	if inPlace, err := cmd.Flags().GetBool("in-place"); err == nil && inPlace {
+		GlobalConfig.InPlace = true
+	}
+
+	readSigningFlags(cmd)
+
 	if GlobalConfig.InputFile == "" {
-		log.Fatal("No input file specified")
+		fatal("Error reading arguments", sigerr.ConfigError{Err: errors.New("no input file specified")})
+	}
+
+	if GlobalConfig.InPlace {
+		if GlobalConfig.OutputFile != "" && GlobalConfig.OutputFile != GlobalConfig.InputFile {
+			fatal("Error reading arguments", sigerr.ConfigError{Err: errors.New("an output file cannot be used together with in-place signing")})
+		}
+		GlobalConfig.OutputFile = GlobalConfig.InputFile
 	}
 
 	if GlobalConfig.OutputFile == "" {
 		fileExt := filepath.Ext(GlobalConfig.InputFile)
-		GlobalConfig.OutputFile = fmt.Sprintf("%s-signed.%s", strings.TrimSuffix(filepath.Base(GlobalConfig.InputFile), fileExt), fileExt)
+		GlobalConfig.OutputFile = fmt.Sprintf("%s-signed%s", strings.TrimSuffix(filepath.Base(GlobalConfig.InputFile), fileExt), fileExt)
 	}
 
 	ctx := context.Background()
 
-	timestampConfig := config.TimestampConfig{
-		URLs:   []string{GlobalConfig.TimestampUrl},
-		MsURLs: []string{GlobalConfig.MsTimestampUrl},
+	signer, err := ossign.NewSigner(ctx, &GlobalConfig)
+	if err != nil {
+		fatal("Error getting signer", err)
+	}
+
+	err = SignFile(signer, GlobalConfig.InputFile, GlobalConfig.OutputFile, GlobalConfig.SignatureType, ctx)
+	closeSigner(signer)
+	if err != nil {
+		fatal("Error signing file", err)
+	}
+
+	log.Printf("Successfully signed %s to %s", GlobalConfig.InputFile, GlobalConfig.OutputFile)
+
+	log.Println("Finished signing!")
+}
+
+// Release the signing key once all files are signed. Failing to do so does not
+// affect the signatures, so it is only logged.
+func closeSigner(signer *ossign.Signer) {
+	if err := signer.Close(); err != nil {
+		log.Printf("Error closing signer: %v", err)
 	}
+}
 
-	timestamper, err := tsclient.New(&timestampConfig)
+// Sign a single file on disk and write the result to outputFile
+func SignFile(signer *ossign.Signer, inputFile, outputFile string, signType ossign.SignatureType, ctx context.Context) error {
+	file, err := vfs.ReadFromFile(inputFile)
 	if err != nil {
-		log.Fatalf("Error creating timestamper: %v", err)
+		return fmt.Errorf("Error reading input file: %w", err)
 	}
 
-	signerCert, err := GlobalConfig.GetSigner(timestamper, ctx)
+	signed, err := signer.Sign(ctx, file, file.Size(), signType, &ossign.SignOptions{Filename: inputFile})
 	if err != nil {
-		log.Fatalf("Error getting signer: %v", err)
+		return err
 	}
 
-	file, err := vfs.ReadFromFile(GlobalConfig.InputFile)
+	blob, err := io.ReadAll(signed)
 	if err != nil {
-		log.Fatalf("Error reading input file: %v", err)
+		return fmt.Errorf("Error reading signed file: %w", err)
 	}
 
-	outfileFdesc := rvfs.New([]byte{}, GlobalConfig.OutputFile)
+	outfileFdesc := rvfs.New(blob, outputFile)
 
-	if GlobalConfig.SignatureType != "" && MapTypeToFunc[GlobalConfig.SignatureType] != nil {
-		err = MapTypeToFunc[GlobalConfig.SignatureType](file, signerCert, GlobalConfig.InputFile, outfileFdesc, ctx)
+	// Signing in place replaces the original atomically so that an interrupted
+	// write never leaves a half-signed file behind
+	if filepath.Clean(inputFile) == filepath.Clean(outputFile) {
+		err = vfs.ReplaceFile(outfileFdesc)
 	} else {
-		log.Fatalf("No sign type specified or unsupported sign type: %s (auto not implemented yet)", GlobalConfig.SignatureType)
-		// err = SignAuto(file, signerCert, GlobalConfig.InputFile, outfileFdesc, ctx)
+		err = vfs.WriteToFile(outfileFdesc)
 	}
 	if err != nil {
-		log.Fatalf("Error signing file: %v", err)
-	}
-
-	if err := vfs.WriteToFile(outfileFdesc); err != nil {
-		log.Fatalf("Error writing output file: %v", err)
+		return fmt.Errorf("Error writing output file: %w", err)
 	}
 
-	log.Printf("Successfully signed %s to %s", GlobalConfig.InputFile, GlobalConfig.OutputFile)
-
-	log.Println("Finished signing!")
+	return nil
 }
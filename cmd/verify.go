@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/ossign/ossign/pkg/ossign"
+	"github.com/ossign/ossign/pkg/sigerr"
+	"github.com/ossign/ossign/pkg/vfs"
+	"github.com/sassoftware/relic/v8/lib/x509tools"
+	"github.com/spf13/cobra"
+)
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify [file]",
+	Short: "Verify the signatures of a signed file",
+	Args:  cobra.ExactArgs(1),
+	Run:   RunVerify,
+}
+
+func RunVerify(cmd *cobra.Command, args []string) {
+	filename := args[0]
+
+	file, err := vfs.ReadFromFile(filename)
+	if err != nil {
+		fatal("Error reading input file", err)
+	}
+
+	signType, err := cmd.Flags().GetString("sign-type")
+	if err != nil {
+		fatal("Error reading sign type", sigerr.ConfigError{Err: err})
+	}
+
+	sigs, err := ossign.Verify(file, file.Size(), ossign.SignatureType(signType), filename)
+	if err != nil {
+		fatal("Error verifying "+filename, err)
+	}
+
+	for i, sig := range sigs {
+		kind := string(sig.Format)
+		if sig.Nested {
+			kind += ", nested"
+		}
+		fmt.Printf("%s: signature %d of %d (%s)\n", filename, i+1, len(sigs), kind)
+		printSignature(sig)
+	}
+
+	log.Printf("Verified %s OK", filename)
+}
+
+func printSignature(sig ossign.VerifiedSignature) {
+	fmt.Printf("  Signer:      %s\n", x509tools.FormatSubject(sig.Certificate))
+	fmt.Printf("  Issuer:      %s\n", x509tools.FormatIssuer(sig.Certificate))
+	fmt.Printf("  Hash:        %s\n", x509tools.HashNames[sig.HashFunc])
+	if sig.PageHashFunc != 0 {
+		fmt.Printf("  Page hashes: %s\n", x509tools.HashNames[sig.PageHashFunc])
+	} else {
+		fmt.Printf("  Page hashes: none\n")
+	}
+	if cs := sig.CounterSignature; cs != nil {
+		fmt.Printf("  Timestamp:   %s\n", cs.SigningTime)
+		fmt.Printf("  TSA:         %s\n", x509tools.FormatSubject(cs.Certificate))
+	} else {
+		fmt.Printf("  Timestamp:   none\n")
+	}
+}
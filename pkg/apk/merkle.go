@@ -0,0 +1,105 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package apk
+
+import (
+	"crypto"
+	"encoding/binary"
+)
+
+const merkleBlock = 1048576
+
+// Compute the chunked digests of the APK signature scheme: each section of
+// the file is split into 1 MiB chunks, and the digests of all chunks are
+// digested again.
+// https://source.android.com/security/apksigning/v2#integrity-protected-contents
+type merkleHasher struct {
+	hashes []crypto.Hash
+	blocks [][]byte
+	buf    []byte
+	n      int
+	count  uint32
+}
+
+func newMerkleHasher(hashes []crypto.Hash) *merkleHasher {
+	return &merkleHasher{
+		buf:    make([]byte, merkleBlock),
+		hashes: hashes,
+		blocks: make([][]byte, len(hashes)),
+	}
+}
+
+func (h *merkleHasher) block(block []byte) {
+	var pref [5]byte
+	pref[0] = 0xa5
+	binary.LittleEndian.PutUint32(pref[1:], uint32(len(block)))
+	for i, hash := range h.hashes {
+		d := hash.New()
+		d.Write(pref[:])
+		d.Write(block)
+		h.blocks[i] = d.Sum(h.blocks[i])
+	}
+	h.count++
+}
+
+func (h *merkleHasher) Write(d []byte) (int, error) {
+	w := len(d)
+	// completing previously buffered data
+	if h.n != 0 && h.n+len(d) >= merkleBlock {
+		n := h.n
+		copy(h.buf[n:merkleBlock], d)
+		d = d[merkleBlock-n:]
+		h.block(h.buf)
+		h.n = 0
+	}
+	// larger than a block -- hash it directly
+	for len(d) >= merkleBlock {
+		h.block(d[:merkleBlock])
+		d = d[merkleBlock:]
+	}
+	// save the rest for later
+	if len(d) != 0 {
+		copy(h.buf[h.n:], d)
+		h.n += len(d)
+	}
+	return w, nil
+}
+
+// End a section of the file. Chunks never span sections.
+func (h *merkleHasher) Section() {
+	if h.n != 0 {
+		h.block(h.buf[:h.n])
+		h.n = 0
+	}
+}
+
+// Return the top level digest for each hash, after the zip entries, central
+// directory and end of central directory were written as separate sections
+func (h *merkleHasher) Sum() [][]byte {
+	h.Section()
+	var pref [5]byte
+	pref[0] = 0x5a
+	binary.LittleEndian.PutUint32(pref[1:], h.count)
+	ret := make([][]byte, len(h.hashes))
+	for i, hash := range h.hashes {
+		master := hash.New()
+		master.Write(pref[:])
+		master.Write(h.blocks[i])
+		ret[i] = master.Sum(nil)
+	}
+	return ret
+}
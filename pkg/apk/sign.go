@@ -0,0 +1,336 @@
+package apk
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/sassoftware/relic/v8/lib/binpatch"
+	"github.com/sassoftware/relic/v8/lib/certloader"
+	"github.com/sassoftware/relic/v8/lib/pkcs7"
+	"github.com/sassoftware/relic/v8/lib/pkcs9"
+	"github.com/sassoftware/relic/v8/lib/signjar"
+	"github.com/sassoftware/relic/v8/lib/x509tools"
+	"github.com/sassoftware/relic/v8/lib/zipslicer"
+)
+
+const (
+	metaInf      = "META-INF/"
+	manifestName = metaInf + "MANIFEST.MF"
+	createdBy    = "OSSign"
+)
+
+type SignOptions struct {
+	// Add a v1 (JAR) signature for Android versions before 7.0
+	V1 bool
+	// Name of the v1 signature files, e.g. ALIAS.SF and ALIAS.RSA
+	Alias string
+}
+
+// Sign an APK with the v2 and v3 signature schemes, and optionally v1. The
+// entries of the archive are kept in place so that zipalign padding stays
+// valid; the v1 signature files are added after them, and the signing block
+// is inserted in front of the central directory. Existing signatures are
+// replaced.
+func Sign(ctx context.Context, inz *zipslicer.Directory, cert *certloader.Certificate, hash crypto.Hash, opts SignOptions) (*binpatch.PatchSet, error) {
+	st, err := sigTypeFor(x509tools.GetPublicKeyAlgorithm(cert.Leaf.PublicKey), hash)
+	if err != nil {
+		return nil, err
+	}
+	if len(inz.File) == 0 {
+		return nil, errors.New("no files in APK")
+	}
+	hashName := x509tools.HashNames[hash]
+
+	hasher := newMerkleHasher([]crypto.Hash{hash})
+	outz := new(zipslicer.Directory)
+	patch := binpatch.New()
+	manifest := &signjar.FilesMap{
+		Main: http.Header{
+			"Manifest-Version": []string{"1.0"},
+			"Created-By":       []string{createdBy},
+		},
+		Files: make(map[string]http.Header),
+	}
+	var contentEnd int64
+	for _, f := range inz.File {
+		if int64(f.Offset) != contentEnd {
+			return nil, fmt.Errorf("unexpected data in front of %s", f.Name)
+		}
+		if isSignatureFile(f.Name) {
+			// drop the old v1 signature
+			size, err := f.GetTotalSize()
+			if err != nil {
+				return nil, err
+			}
+			patch.Add(int64(f.Offset), size, nil)
+			contentEnd += size
+			continue
+		}
+
+		lfh, err := f.GetLocalHeader()
+		if err != nil {
+			return nil, err
+		}
+		if opts.V1 && !strings.HasSuffix(f.Name, "/") {
+			// digest the contents for the manifest and the raw entry for
+			// the signing block in one pass
+			hasher.Write(lfh)
+			r, err := f.OpenAndTeeRaw(hasher)
+			if err != nil {
+				return nil, err
+			}
+			d := hash.New()
+			if _, err := io.Copy(d, r); err != nil {
+				return nil, fmt.Errorf("digesting %s: %w", f.Name, err)
+			}
+			if err := r.Close(); err != nil {
+				return nil, err
+			}
+			ddb, err := f.GetDataDescriptor()
+			if err != nil {
+				return nil, err
+			}
+			hasher.Write(ddb)
+			manifest.Files[f.Name] = http.Header{
+				"Name":               []string{f.Name},
+				hashName + "-Digest": []string{base64.StdEncoding.EncodeToString(d.Sum(nil))},
+			}
+			manifest.Order = append(manifest.Order, f.Name)
+		} else if _, err := f.Dump(hasher); err != nil {
+			return nil, err
+		}
+
+		size, err := f.GetTotalSize()
+		if err != nil {
+			return nil, err
+		}
+		contentEnd += size
+		oldData := int64(f.Offset) + int64(len(lfh))
+		if _, err := outz.AddFile(f); err != nil {
+			return nil, err
+		}
+		newData := int64(f.Offset) + int64(len(lfh))
+		if f.Method == zip.Store && newData != oldData {
+			if a := alignment(f.Name); oldData%a == 0 && newData%a != 0 {
+				return nil, fmt.Errorf("removing the old signature would break the alignment of %s, run zipalign and sign again", f.Name)
+			}
+		}
+	}
+	if contentEnd > inz.DirLoc {
+		return nil, errors.New("zip entries overlap the central directory")
+	}
+
+	var v1 bytes.Buffer
+	if opts.V1 {
+		if err := signV1(ctx, outz, &v1, manifest.Dump(), cert, hash, opts.Alias); err != nil {
+			return nil, err
+		}
+		hasher.Write(v1.Bytes())
+	}
+	hasher.Section()
+
+	// the central directory is digested as if it followed the entries directly
+	sigLoc := outz.DirLoc
+	if sigLoc >= 1<<32 {
+		return nil, errors.New("ZIP64 is not supported by Android")
+	}
+	var cd, eocd bytes.Buffer
+	if err := outz.WriteDirectory(&cd, &eocd, false); err != nil {
+		return nil, err
+	}
+	hasher.Write(cd.Bytes())
+	hasher.Section()
+	hasher.Write(eocd.Bytes())
+	digest := hasher.Sum()[0]
+
+	block, err := signingBlock(cert, st, digest)
+	if err != nil {
+		return nil, err
+	}
+	// replaces an old signing block as well
+	patch.Add(contentEnd, inz.DirLoc-contentEnd, append(v1.Bytes(), block...))
+	outz.DirLoc = sigLoc + int64(len(block))
+	var dir bytes.Buffer
+	if err := outz.WriteDirectory(&dir, &dir, false); err != nil {
+		return nil, err
+	}
+	patch.Add(inz.DirLoc, inz.Size-inz.DirLoc, dir.Bytes())
+	return patch, nil
+}
+
+// Write the manifest, signature file and PKCS#7 signature of a v1 signature
+// as new entries of outz
+func signV1(ctx context.Context, outz *zipslicer.Directory, w io.Writer, manifest []byte, cert *certloader.Certificate, hash crypto.Hash, alias string) error {
+	sf, err := signjar.DigestManifest(manifest, hash, false, true)
+	if err != nil {
+		return err
+	}
+	// tell verifiers that know the newer schemes to reject the v1 signature
+	// alone
+	sf = bytes.Replace(sf, []byte("X-Android-APK-Signed: 2\r\n"), []byte("X-Android-APK-Signed: 2, 3\r\n"), 1)
+
+	builder := pkcs7.NewBuilder(cert.Signer(), cert.Chain(), hash)
+	if err := builder.SetContentData(sf); err != nil {
+		return err
+	}
+	psd, err := builder.Sign()
+	if err != nil {
+		return err
+	}
+	if _, err := pkcs9.TimestampAndMarshal(ctx, psd, cert.Timestamper, false); err != nil {
+		return err
+	}
+	if _, err := psd.Detach(); err != nil {
+		return err
+	}
+	sig, err := asn1.Marshal(*psd)
+	if err != nil {
+		return err
+	}
+
+	base := metaInf + strings.ToUpper(alias)
+	ext := ".RSA"
+	if x509tools.GetPublicKeyAlgorithm(cert.Leaf.PublicKey) == x509.ECDSA {
+		ext = ".EC"
+	}
+	mtime := time.Now()
+	for _, file := range []struct {
+		name     string
+		contents []byte
+	}{
+		{manifestName, manifest},
+		{base + ".SF", sf},
+		{base + ext, sig},
+	} {
+		if _, err := outz.NewFile(file.name, nil, file.contents, w, mtime, true, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Build the APK signing block holding a v2 and a v3 signature over the
+// content digest
+func signingBlock(cert *certloader.Certificate, st sigType, digest []byte) ([]byte, error) {
+	digests := []apkDigest{{ID: st.id, Value: digest}}
+	var certs [][]byte
+	for _, c := range cert.Chain() {
+		certs = append(certs, c.Raw)
+	}
+
+	schemeV3 := binary.LittleEndian.AppendUint32(nil, 3)
+	v2Data, err := marshal(apkSignedData{
+		Digests:      digests,
+		Certificates: certs,
+		Attributes:   []apkRaw{makeAttribute(attrStrippingProtection, schemeV3)},
+	})
+	if err != nil {
+		return nil, err
+	}
+	v2Sig, err := signData(cert, st, v2Data.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	v2, err := marshal([]apkSigner{{
+		SignedData: v2Data,
+		Signatures: []apkSignature{{ID: st.id, Value: v2Sig}},
+		PublicKey:  cert.Leaf.RawSubjectPublicKeyInfo,
+	}})
+	if err != nil {
+		return nil, err
+	}
+
+	v3Data, err := marshal(apkV3SignedData{
+		Digests:      digests,
+		Certificates: certs,
+		MinSDK:       v3MinSDK,
+		MaxSDK:       v3MaxSDK,
+	})
+	if err != nil {
+		return nil, err
+	}
+	v3Sig, err := signData(cert, st, v3Data.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	v3, err := marshal([]apkV3Signer{{
+		SignedData: v3Data,
+		MinSDK:     v3MinSDK,
+		MaxSDK:     v3MaxSDK,
+		Signatures: []apkSignature{{ID: st.id, Value: v3Sig}},
+		PublicKey:  cert.Leaf.RawSubjectPublicKeyInfo,
+	}})
+	if err != nil {
+		return nil, err
+	}
+
+	return makeSigBlock([]sigPair{{sigApkV2, v2}, {sigApkV3, v3}}), nil
+}
+
+func signData(cert *certloader.Certificate, st sigType, data []byte) ([]byte, error) {
+	d := st.hash.New()
+	d.Write(data)
+	return cert.Signer().Sign(rand.Reader, d.Sum(nil), st.hash)
+}
+
+type sigPair struct {
+	id    uint32
+	value []byte
+}
+
+// Frame ID-value pairs as an APK signing block
+func makeSigBlock(pairs []sigPair) []byte {
+	size := 8 + len(sigMagic)
+	for _, pair := range pairs {
+		size += 8 + 4 + len(pair.value)
+	}
+	block := make([]byte, 0, 8+size)
+	// the length prefix counts the magic suffix but not itself
+	block = binary.LittleEndian.AppendUint64(block, uint64(size))
+	for _, pair := range pairs {
+		block = binary.LittleEndian.AppendUint64(block, uint64(4+len(pair.value)))
+		block = binary.LittleEndian.AppendUint32(block, pair.id)
+		block = append(block, pair.value...)
+	}
+	block = binary.LittleEndian.AppendUint64(block, uint64(size))
+	return append(block, sigMagic...)
+}
+
+// Files of a v1 signature, which are replaced when signing
+func isSignatureFile(name string) bool {
+	dir, base := path.Split(strings.ToUpper(name))
+	if dir != metaInf || base == "" {
+		return false
+	}
+	if base == "MANIFEST.MF" || strings.HasPrefix(base, "SIG-") {
+		return true
+	}
+	switch path.Ext(base) {
+	case ".SF", ".RSA", ".DSA", ".EC":
+		return true
+	}
+	return false
+}
+
+// zipalign places uncompressed native libraries on page boundaries and all
+// other uncompressed entries on 4 byte boundaries
+func alignment(name string) int64 {
+	if strings.HasSuffix(name, ".so") {
+		return 4096
+	}
+	return 4
+}
@@ -0,0 +1,145 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package apk
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/binary"
+	"fmt"
+)
+
+// IDs of the pairs in the APK signing block
+const (
+	sigMagic = "APK Sig Block 42"
+	sigApkV2 = 0x7109871a
+	sigApkV3 = 0xf05368c0
+
+	// Attribute of v2 signed data naming the newer schemes that were also
+	// used, so that they cannot be stripped
+	attrStrippingProtection = 0xbeeff00d
+
+	// v3 signatures are only checked from Android 9
+	v3MinSDK = 28
+	v3MaxSDK = 0x7fffffff
+)
+
+type apkSigner struct {
+	SignedData apkRaw
+	Signatures []apkSignature
+	PublicKey  []byte
+}
+
+type apkSignedData struct {
+	Digests      []apkDigest
+	Certificates [][]byte
+	Attributes   []apkRaw
+}
+
+type apkV3Signer struct {
+	SignedData apkRaw
+	MinSDK     uint32
+	MaxSDK     uint32
+	Signatures []apkSignature
+	PublicKey  []byte
+}
+
+type apkV3SignedData struct {
+	Digests      []apkDigest
+	Certificates [][]byte
+	MinSDK       uint32
+	MaxSDK       uint32
+	Attributes   []apkRaw
+}
+
+type apkSignature struct {
+	ID    uint32
+	Value []byte
+}
+
+type apkDigest apkSignature
+
+// Unlike digests and signatures, the values of additional attributes have no
+// length prefix of their own
+func makeAttribute(id uint32, value []byte) apkRaw {
+	raw := binary.LittleEndian.AppendUint32(nil, uint32(4+len(value)))
+	raw = binary.LittleEndian.AppendUint32(raw, id)
+	return append(raw, value...)
+}
+
+func parseAttribute(raw apkRaw) (id uint32, value []byte, ok bool) {
+	blob := raw.Bytes()
+	if len(blob) < 4 {
+		return 0, nil, false
+	}
+	return binary.LittleEndian.Uint32(blob), blob[4:], true
+}
+
+func parseCertificates(ders [][]byte) (certs []*x509.Certificate, err error) {
+	certs = make([]*x509.Certificate, len(ders))
+	for i, der := range ders {
+		certs[i], err = x509.ParseCertificate(der)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return
+}
+
+type sigType struct {
+	id   uint32
+	hash crypto.Hash
+	alg  x509.PublicKeyAlgorithm
+	pss  bool
+}
+
+var sigTypes = []sigType{
+	{0x0101, crypto.SHA256, x509.RSA, true},    // RSASSA-PSS with SHA2-256 digest
+	{0x0102, crypto.SHA512, x509.RSA, true},    // RSASSA-PSS with SHA2-512 digest
+	{0x0103, crypto.SHA256, x509.RSA, false},   // RSASSA-PKCS1-v1_5 with SHA2-256 digest
+	{0x0104, crypto.SHA512, x509.RSA, false},   // RSASSA-PKCS1-v1_5 with SHA2-512 digest
+	{0x0201, crypto.SHA256, x509.ECDSA, false}, // ECDSA with SHA2-256 digest
+	{0x0202, crypto.SHA512, x509.ECDSA, false}, // ECDSA with SHA2-512 digest
+	{0x0301, crypto.SHA256, x509.DSA, false},   // DSA with SHA2-256 digest
+}
+
+func sigTypeByID(id uint32) (st sigType, err error) {
+	for _, s := range sigTypes {
+		if s.id == id {
+			st = s
+			break
+		}
+	}
+	if st.id == 0 {
+		return st, fmt.Errorf("unknown signature type 0x%04x", id)
+	}
+	if !st.hash.Available() {
+		return st, fmt.Errorf("unsupported signature type 0x%04x", id)
+	}
+	return
+}
+
+// Select the PKCS#1 v1.5 or ECDSA signature type for a key, as remote key
+// services do not all support PSS
+func sigTypeFor(alg x509.PublicKeyAlgorithm, hash crypto.Hash) (sigType, error) {
+	for _, s := range sigTypes {
+		if s.hash == hash && s.alg == alg && !s.pss {
+			return s, nil
+		}
+	}
+	return sigType{}, fmt.Errorf("APK signatures support %s keys with SHA-256 or SHA-512, not %s", alg, hash)
+}
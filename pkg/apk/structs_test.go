@@ -0,0 +1,51 @@
+package apk
+
+import (
+	"encoding/binary"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAttributeFraming(t *testing.T) {
+	sd, err := marshal(apkSignedData{
+		Attributes: []apkRaw{makeAttribute(attrStrippingProtection, binary.LittleEndian.AppendUint32(nil, 3))},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []byte{
+		24, 0, 0, 0, // signed data
+		0, 0, 0, 0, // no digests
+		0, 0, 0, 0, // no certificates
+		12, 0, 0, 0, // attributes
+		8, 0, 0, 0, 0x0d, 0xf0, 0xef, 0xbe, 3, 0, 0, 0,
+	}, []byte(sd))
+
+	var parsed apkSignedData
+	require.NoError(t, unmarshal(sd, &parsed))
+	require.Len(t, parsed.Attributes, 1)
+	id, value, ok := parseAttribute(parsed.Attributes[0])
+	assert.True(t, ok)
+	assert.Equal(t, uint32(attrStrippingProtection), id)
+	assert.Equal(t, []byte{3, 0, 0, 0}, value)
+}
+
+func TestUnmarshalTruncated(t *testing.T) {
+	sd, err := marshal(apkSignedData{
+		Digests:    []apkDigest{{ID: 0x0103, Value: []byte{1, 2, 3, 4}}},
+		Attributes: []apkRaw{makeAttribute(attrStrippingProtection, binary.LittleEndian.AppendUint32(nil, 3))},
+	})
+	require.NoError(t, err)
+	for n := 0; n < len(sd); n++ {
+		var parsed apkSignedData
+		assert.Error(t, unmarshal(sd[:n], &parsed), "truncated to %d bytes", n)
+	}
+
+	// a length prefix slightly beyond the data
+	blob := append(binary.LittleEndian.AppendUint32(nil, 6), 1, 2, 3, 4)
+	var value []byte
+	assert.ErrorIs(t, unmarshal(blob, &value), io.ErrUnexpectedEOF)
+	blob = append(binary.LittleEndian.AppendUint32(nil, 0xffffffff), 1, 2, 3, 4)
+	assert.ErrorIs(t, unmarshal(blob, &value), io.ErrUnexpectedEOF)
+}
@@ -0,0 +1,342 @@
+package apk
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/sassoftware/relic/v8/lib/pkcs7"
+	"github.com/sassoftware/relic/v8/lib/pkcs9"
+	"github.com/sassoftware/relic/v8/lib/signjar"
+	"github.com/sassoftware/relic/v8/lib/x509tools"
+	"github.com/sassoftware/relic/v8/lib/zipslicer"
+)
+
+var (
+	errMalformed = errors.New("malformed APK signing block")
+	errTruncated = errors.New("truncated APK signing block sequence")
+)
+
+// A verified signature of an APK
+type Signature struct {
+	pkcs9.TimestampedSignature
+	Hash crypto.Hash
+	// Signature scheme: 1 (JAR), 2 or 3
+	Scheme int
+}
+
+// Verify the v1, v2 and v3 signatures of an APK, including the digests of its
+// contents. Certificates are not checked against a trust store, as Android
+// does not either.
+func Verify(r io.ReaderAt, size int64) ([]Signature, error) {
+	inz, err := zipslicer.Read(r, size)
+	if err != nil {
+		return nil, err
+	}
+	if len(inz.File) == 0 {
+		return nil, errors.New("no files in APK")
+	}
+	sigLoc, err := inz.NextFileOffset()
+	if err != nil {
+		return nil, err
+	}
+
+	var sigs []Signature
+	stripped := false
+	if sigLoc != inz.DirLoc {
+		block, err := readSigBlock(r, sigLoc, inz.DirLoc)
+		if err != nil {
+			return nil, err
+		}
+		sections, err := readSections(r, size, sigLoc, inz.DirLoc)
+		if err != nil {
+			return nil, err
+		}
+		for len(block) > 0 {
+			if len(block) < 12 {
+				return nil, errTruncated
+			}
+			pairSize := binary.LittleEndian.Uint64(block)
+			block = block[8:]
+			if pairSize < 4 || pairSize > uint64(len(block)) {
+				return nil, errTruncated
+			}
+			id := binary.LittleEndian.Uint32(block)
+			value := block[4:pairSize]
+			block = block[pairSize:]
+
+			switch id {
+			case sigApkV2:
+				var signers []apkSigner
+				if err := unmarshal(value, &signers); err != nil {
+					return nil, fmt.Errorf("parsing v2 signature block: %w", err)
+				}
+				for i, signer := range signers {
+					var sd apkSignedData
+					sig, err := verifySigner(signer.SignedData, signer.Signatures, signer.PublicKey, &sd, sections)
+					if err != nil {
+						return nil, fmt.Errorf("v2 signature #%d: %w", i+1, err)
+					}
+					for _, attr := range sd.Attributes {
+						id, value, ok := parseAttribute(attr)
+						if ok && id == attrStrippingProtection && len(value) == 4 && binary.LittleEndian.Uint32(value) == 3 {
+							stripped = true
+						}
+					}
+					sig.Scheme = 2
+					sigs = append(sigs, *sig)
+				}
+			case sigApkV3:
+				var signers []apkV3Signer
+				if err := unmarshal(value, &signers); err != nil {
+					return nil, fmt.Errorf("parsing v3 signature block: %w", err)
+				}
+				for i, signer := range signers {
+					var sd apkV3SignedData
+					sig, err := verifySigner(signer.SignedData, signer.Signatures, signer.PublicKey, &sd, sections)
+					if err != nil {
+						return nil, fmt.Errorf("v3 signature #%d: %w", i+1, err)
+					}
+					if sd.MinSDK != signer.MinSDK || sd.MaxSDK != signer.MaxSDK {
+						return nil, fmt.Errorf("v3 signature #%d: SDK versions of the signed data do not match", i+1)
+					}
+					sig.Scheme = 3
+					sigs = append(sigs, *sig)
+				}
+			}
+		}
+	}
+	if stripped && !hasScheme(sigs, 3) {
+		return nil, errors.New("v2 signature requires a v3 signature, which has been removed")
+	}
+
+	v1, err := verifyV1(r, size, inz)
+	if err != nil {
+		return nil, err
+	}
+	for _, sig := range v1 {
+		apkSigned := sig.SignatureHeader.Get("X-Android-APK-Signed")
+		for _, scheme := range []int{2, 3} {
+			if strings.ContainsRune(apkSigned, rune('0'+scheme)) && !hasScheme(sigs, scheme) {
+				return nil, fmt.Errorf("v1 signature requires a v%d signature, which has been removed", scheme)
+			}
+		}
+		sigs = append(sigs, Signature{TimestampedSignature: sig.TimestampedSignature, Hash: sig.Hash, Scheme: 1})
+	}
+
+	if len(sigs) == 0 {
+		return nil, errors.New("APK is not signed")
+	}
+	return sigs, nil
+}
+
+func hasScheme(sigs []Signature, scheme int) bool {
+	for _, sig := range sigs {
+		if sig.Scheme == scheme {
+			return true
+		}
+	}
+	return false
+}
+
+// Return the ID-value pairs of the signing block between the zip entries and
+// the central directory
+func readSigBlock(r io.ReaderAt, sigLoc, dirLoc int64) ([]byte, error) {
+	blob := make([]byte, dirLoc-sigLoc)
+	if _, err := r.ReadAt(blob, sigLoc); err != nil {
+		return nil, err
+	}
+	if len(blob) < 32 || !bytes.HasSuffix(blob, []byte(sigMagic)) {
+		return nil, errMalformed
+	}
+	expected := uint64(len(blob) - 8)
+	if binary.LittleEndian.Uint64(blob) != expected || binary.LittleEndian.Uint64(blob[len(blob)-24:]) != expected {
+		return nil, errMalformed
+	}
+	return blob[8 : len(blob)-24], nil
+}
+
+// The digested sections of a signed APK: the zip entries, the central
+// directory, and the end of central directory pointing at the signing block
+type apkSections struct {
+	r             io.ReaderAt
+	sigLoc        int64
+	dir, endOfDir []byte
+}
+
+func readSections(r io.ReaderAt, size, sigLoc, dirLoc int64) (*apkSections, error) {
+	tail := make([]byte, size-dirLoc)
+	if _, err := r.ReadAt(tail, dirLoc); err != nil {
+		return nil, err
+	}
+	if bytes.Contains(tail, []byte("PK\x06\x06")) {
+		return nil, errors.New("ZIP64 is not supported by Android")
+	}
+	end := bytes.LastIndex(tail, []byte("PK\x05\x06"))
+	if end < 0 || len(tail)-end < 22 {
+		return nil, errors.New("end of central directory not found")
+	}
+	endOfDir := append([]byte(nil), tail[end:]...)
+	binary.LittleEndian.PutUint32(endOfDir[16:], uint32(sigLoc))
+	return &apkSections{r: r, sigLoc: sigLoc, dir: tail[:end], endOfDir: endOfDir}, nil
+}
+
+func (s *apkSections) digest(hashes []crypto.Hash) ([][]byte, error) {
+	hasher := newMerkleHasher(hashes)
+	if _, err := io.Copy(hasher, io.NewSectionReader(s.r, 0, s.sigLoc)); err != nil {
+		return nil, err
+	}
+	hasher.Section()
+	hasher.Write(s.dir)
+	hasher.Section()
+	hasher.Write(s.endOfDir)
+	return hasher.Sum(), nil
+}
+
+// Check the signatures over the signed data of a v2 or v3 signer, parse it
+// into sd and compare its digests with the contents of the APK
+func verifySigner(signedData apkRaw, signatures []apkSignature, publicKey []byte, sd interface{}, sections *apkSections) (*Signature, error) {
+	if len(signatures) == 0 {
+		return nil, errors.New("no signatures in signer block")
+	}
+	pub, err := x509.ParsePKIXPublicKey(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	var bestHash crypto.Hash
+	for _, sig := range signatures {
+		hash, err := sig.verify(pub, signedData.Bytes())
+		if err != nil {
+			return nil, err
+		}
+		if hash > bestHash {
+			bestHash = hash
+		}
+	}
+
+	if err := unmarshal(signedData, sd); err != nil {
+		return nil, err
+	}
+	var digests []apkDigest
+	var certs [][]byte
+	switch sd := sd.(type) {
+	case *apkSignedData:
+		digests, certs = sd.Digests, sd.Certificates
+	case *apkV3SignedData:
+		digests, certs = sd.Digests, sd.Certificates
+	}
+	if len(digests) == 0 {
+		return nil, errors.New("no digests in signed data")
+	}
+	hashes := make([]crypto.Hash, len(digests))
+	for i, digest := range digests {
+		st, err := sigTypeByID(digest.ID)
+		if err != nil {
+			return nil, err
+		}
+		hashes[i] = st.hash
+	}
+	calculated, err := sections.digest(hashes)
+	if err != nil {
+		return nil, err
+	}
+	for i, digest := range digests {
+		if !hmac.Equal(digest.Value, calculated[i]) {
+			return nil, fmt.Errorf("digest mismatch for algorithm 0x%04x", digest.ID)
+		}
+	}
+
+	parsed, err := parseCertificates(certs)
+	if err != nil {
+		return nil, err
+	}
+	var leaf *x509.Certificate
+	var intermediates []*x509.Certificate
+	for _, cert := range parsed {
+		if leaf == nil && bytes.Equal(cert.RawSubjectPublicKeyInfo, publicKey) {
+			leaf = cert
+		} else {
+			intermediates = append(intermediates, cert)
+		}
+	}
+	if leaf == nil {
+		return nil, errors.New("public key does not match any certificate")
+	}
+	return &Signature{
+		TimestampedSignature: pkcs9.TimestampedSignature{
+			Signature: pkcs7.Signature{Certificate: leaf, Intermediates: intermediates},
+		},
+		Hash: bestHash,
+	}, nil
+}
+
+func (sig *apkSignature) verify(publicKey interface{}, signedData []byte) (crypto.Hash, error) {
+	st, err := sigTypeByID(sig.ID)
+	if err != nil {
+		return 0, err
+	}
+	d := st.hash.New()
+	d.Write(signedData)
+	hashed := d.Sum(nil)
+	switch st.alg {
+	case x509.RSA:
+		pub, ok := publicKey.(*rsa.PublicKey)
+		if !ok {
+			return 0, errors.New("public key algorithm mismatch")
+		}
+		if st.pss {
+			err = rsa.VerifyPSS(pub, st.hash, hashed, sig.Value, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		} else {
+			err = rsa.VerifyPKCS1v15(pub, st.hash, hashed, sig.Value)
+		}
+		if err != nil {
+			return 0, err
+		}
+	case x509.ECDSA:
+		pub, ok := publicKey.(*ecdsa.PublicKey)
+		if !ok {
+			return 0, errors.New("public key algorithm mismatch")
+		}
+		esig, err := x509tools.UnmarshalEcdsaSignature(sig.Value)
+		if err != nil {
+			return 0, err
+		}
+		if !ecdsa.Verify(pub, hashed, esig.R, esig.S) {
+			return 0, errors.New("ECDSA verification failed")
+		}
+	default:
+		return 0, errors.New("unsupported public key algorithm")
+	}
+	return st.hash, nil
+}
+
+// Verify the JAR signature, if the APK has one
+func verifyV1(r io.ReaderAt, size int64, inz *zipslicer.Directory) ([]*signjar.JarSignature, error) {
+	signed := false
+	for _, f := range inz.File {
+		if isSignatureFile(f.Name) && !strings.EqualFold(f.Name, manifestName) {
+			signed = true
+		}
+	}
+	if !signed {
+		return nil, nil
+	}
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	sigs, err := signjar.Verify(zr, false)
+	if err != nil {
+		return nil, fmt.Errorf("v1 signature: %w", err)
+	}
+	return sigs, nil
+}
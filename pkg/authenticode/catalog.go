@@ -82,7 +82,42 @@ func (cat *Catalog) Sign(ctx context.Context, cert *certloader.Certificate, para
 	return pkcs9.TimestampAndMarshal(ctx, psd, cert.Timestamper, true)
 }
 
+// Name and value attribute of a catalog member, such as "File" or "OSAttr"
+type CatalogAttribute struct {
+	Name  string
+	Value string
+}
+
+// CAT_NAMEVALUE as stored in the member entries
+type CatNameValue struct {
+	Tag   asn1.RawValue // BMPString
+	Flags int
+	Value []byte // UTF-16LE with a terminating NUL
+}
+
+// CRYPTCAT_ATTR_AUTHENTICATED | CRYPTCAT_ATTR_NAMEASCII | CRYPTCAT_ATTR_DATAASCII
+const catalogAttrFlags = 0x10010001
+
+// Make the indirect data of a catalog member from the Authenticode digest of a
+// PE image, or the flat digest of any other file
+func MakeCatalogIndirect(imprint []byte, hash crypto.Hash, pe bool) (SpcIndirectDataContentPe, error) {
+	oid := OidSpcCabImageData
+	if pe {
+		oid = OidSpcPeImageData
+	}
+	return makePeIndirect(imprint, hash, oid)
+}
+
 func (cat *Catalog) Add(indirect SpcIndirectDataContentPe) error {
+	return cat.AddMember(indirect, nil)
+}
+
+// Add a member with name and value attributes
+func (cat *Catalog) AddMember(indirect SpcIndirectDataContentPe, attrs []CatalogAttribute) error {
+	attrValues, err := makeAttrValues(attrs)
+	if err != nil {
+		return err
+	}
 	sha2 := !indirect.MessageDigest.DigestAlgorithm.Algorithm.Equal(x509tools.OidDigestSHA1)
 	if sha2 && cat.Version == 1 {
 		return errors.New("can't add SHA2 digest to v1 catalog")
@@ -105,7 +140,7 @@ func (cat *Catalog) Add(indirect SpcIndirectDataContentPe) error {
 		catValue := CertTrustValue{Attribute: OidCatalogMemberInfo, Value: makeSet(memberInfoEnc)}
 		cat.Sha1Entries = append(cat.Sha1Entries, CertTrustEntry{
 			Tag:    tagV1(value),
-			Values: []CertTrustValue{indirectEntry, catValue},
+			Values: append([]CertTrustValue{indirectEntry, catValue}, attrValues...),
 		})
 	} else {
 		// this supposed to always be empty?
@@ -114,18 +149,35 @@ func (cat *Catalog) Add(indirect SpcIndirectDataContentPe) error {
 		if sha2 {
 			cat.Sha2Entries = append(cat.Sha2Entries, CertTrustEntry{
 				Tag:    value,
-				Values: []CertTrustValue{catValue, indirectEntry},
+				Values: append([]CertTrustValue{catValue, indirectEntry}, attrValues...),
 			})
 		} else {
 			cat.Sha1Entries = append(cat.Sha1Entries, CertTrustEntry{
 				Tag:    value,
-				Values: []CertTrustValue{catValue},
+				Values: append([]CertTrustValue{catValue}, attrValues...),
 			})
 		}
 	}
 	return nil
 }
 
+func makeAttrValues(attrs []CatalogAttribute) ([]CertTrustValue, error) {
+	var values []CertTrustValue
+	for _, attr := range attrs {
+		runes := utf16.Encode([]rune(attr.Value + "\x00"))
+		value := make([]byte, 2*len(runes))
+		for i, r := range runes {
+			binary.LittleEndian.PutUint16(value[i*2:], r)
+		}
+		enc, err := asn1.Marshal(CatNameValue{Tag: x509tools.ToBMPString(attr.Name), Flags: catalogAttrFlags, Value: value})
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, CertTrustValue{Attribute: OidCatalogNameValue, Value: makeSet(enc)})
+	}
+	return values, nil
+}
+
 func tagV1(value []byte) []byte {
 	// The tag is a UTF-16-LE encoding of the hex of the imprint
 	runes := utf16.Encode([]rune(hex.EncodeToString(value)))
@@ -0,0 +1,121 @@
+package authenticode
+
+import (
+	"crypto"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"github.com/sassoftware/relic/v8/lib/pkcs7"
+	"github.com/sassoftware/relic/v8/lib/pkcs9"
+	"github.com/sassoftware/relic/v8/lib/x509tools"
+)
+
+// A file listed in a catalog
+type CatalogMember struct {
+	Digest   []byte
+	HashFunc crypto.Hash
+	// The digest is the Authenticode digest of a PE image rather than that of
+	// the whole file
+	PE         bool
+	Attributes map[string]string
+}
+
+type CatalogSignature struct {
+	pkcs9.TimestampedSignature
+	HashFunc crypto.Hash
+	Members  []CatalogMember
+}
+
+// CertTrustList with the catalog attributes left unparsed
+type certTrustListRaw struct {
+	SubjectUsage     []asn1.ObjectIdentifier
+	ListIdentifier   []byte
+	EffectiveDate    time.Time
+	SubjectAlgorithm pkix.AlgorithmIdentifier
+	Entries          []CertTrustEntry
+	Attributes       asn1.RawValue `asn1:"optional"`
+}
+
+// Verify the signature of a catalog file and return its members. Does not
+// check X509 chains.
+func VerifyCatalog(blob []byte) (*CatalogSignature, error) {
+	psd, err := pkcs7.Unmarshal(blob)
+	if err != nil {
+		return nil, err
+	}
+	if !psd.Content.ContentInfo.ContentType.Equal(OidCertTrustList) {
+		return nil, errors.New("not a catalog file")
+	}
+	pksig, err := psd.Content.Verify(nil, false)
+	if err != nil {
+		return nil, err
+	}
+	ts, err := pkcs9.VerifyOptionalTimestamp(pksig)
+	if err != nil {
+		return nil, err
+	}
+	hash, err := x509tools.PkixDigestToHashE(pksig.SignerInfo.DigestAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	var ctl certTrustListRaw
+	if err := psd.Content.ContentInfo.Unmarshal(&ctl); err != nil {
+		return nil, fmt.Errorf("parsing catalog: %w", err)
+	}
+	catsig := &CatalogSignature{TimestampedSignature: ts, HashFunc: hash}
+	for _, entry := range ctl.Entries {
+		member, err := parseCatalogEntry(entry)
+		if err != nil {
+			return nil, fmt.Errorf("parsing catalog member: %w", err)
+		}
+		catsig.Members = append(catsig.Members, member)
+	}
+	return catsig, nil
+}
+
+func parseCatalogEntry(entry CertTrustEntry) (CatalogMember, error) {
+	member := CatalogMember{Attributes: make(map[string]string)}
+	for _, value := range entry.Values {
+		switch {
+		case value.Attribute.Equal(OidSpcIndirectDataContent):
+			var indirect SpcIndirectDataContentPe
+			if _, err := asn1.Unmarshal(value.Value.Bytes, &indirect); err != nil {
+				return member, err
+			}
+			hash, err := x509tools.PkixDigestToHashE(indirect.MessageDigest.DigestAlgorithm)
+			if err != nil {
+				return member, err
+			}
+			member.Digest = indirect.MessageDigest.Digest
+			member.HashFunc = hash
+			member.PE = indirect.Data.Type.Equal(OidSpcPeImageData)
+		case value.Attribute.Equal(OidCatalogNameValue):
+			var nv CatNameValue
+			if _, err := asn1.Unmarshal(value.Value.Bytes, &nv); err != nil {
+				return member, err
+			}
+			member.Attributes[SpcString{Unicode: nv.Tag.Bytes}.String()] = decodeUTF16LE(nv.Value)
+		}
+	}
+	if member.Digest == nil {
+		// SHA-1 entries of v2 catalogs only carry the digest as their tag
+		member.Digest = entry.Tag
+		member.HashFunc = crypto.SHA1
+	}
+	return member, nil
+}
+
+func decodeUTF16LE(raw []byte) string {
+	words := make([]uint16, len(raw)/2)
+	for i := range words {
+		words[i] = binary.LittleEndian.Uint16(raw[i*2:])
+	}
+	return strings.TrimRight(string(utf16.Decode(words)), "\x00")
+}
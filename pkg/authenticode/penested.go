@@ -0,0 +1,111 @@
+package authenticode
+
+import (
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/sassoftware/relic/v8/lib/pkcs7"
+)
+
+// Read the first signature from the certificate table of a PE image. Returns
+// nil if the image is not signed.
+func ReadPESignature(r io.ReadSeeker) ([]byte, error) {
+	hvals, err := findSignatures(r)
+	if err != nil {
+		return nil, err
+	} else if hvals.certSize == 0 {
+		return nil, nil
+	}
+	if hvals.certSize < 8 {
+		return nil, errors.New("invalid certificate table")
+	}
+	if _, err := r.Seek(hvals.certStart, 0); err != nil {
+		return nil, err
+	}
+	var info certInfo
+	if err := binary.Read(r, binary.LittleEndian, &info); err != nil {
+		return nil, err
+	}
+	if int64(info.Length) > hvals.certSize || info.Length < 8 {
+		return nil, errors.New("invalid certificate table")
+	}
+	blob := make([]byte, info.Length-8)
+	if _, err := io.ReadFull(r, blob); err != nil {
+		return nil, err
+	}
+	return blob, nil
+}
+
+// Add a signature to the unauthenticated attributes of another, so that both
+// can be stored in a single certificate table entry. Windows checks nested
+// signatures in addition to the primary one.
+func NestSignature(primary, nested []byte) ([]byte, error) {
+	psd, err := pkcs7.Unmarshal(primary)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshaling primary signature: %w", err)
+	}
+	if len(psd.Content.SignerInfos) != 1 {
+		return nil, errors.New("primary signature must have exactly one signer")
+	}
+	nestedPsd, err := pkcs7.Unmarshal(nested)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshaling nested signature: %w", err)
+	}
+
+	signerInfo := &psd.Content.SignerInfos[0]
+	for i, attr := range signerInfo.UnauthenticatedAttributes {
+		// the original encoding would hide the appended value
+		if attr.Type.Equal(OidSpcNestedSignature) {
+			signerInfo.UnauthenticatedAttributes[i].Values.FullBytes = nil
+		}
+	}
+	if err := signerInfo.UnauthenticatedAttributes.Add(OidSpcNestedSignature, *nestedPsd); err != nil {
+		return nil, err
+	}
+	if err := replaceUnauthenticatedAttributes(signerInfo); err != nil {
+		return nil, err
+	}
+	blob, err := psd.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	// make sure the primary signature survived being re-encoded
+	if _, err := checkSignature(blob); err != nil {
+		return nil, fmt.Errorf("primary signature no longer verifies: %w", err)
+	}
+	return blob, nil
+}
+
+// A parsed SignerInfo is marshaled from its original encoding, which keeps the
+// authenticated attributes exactly as they were signed. Swap only the
+// unauthenticated attributes in that encoding.
+func replaceUnauthenticatedAttributes(si *pkcs7.SignerInfo) error {
+	var seq []asn1.RawValue
+	if _, err := asn1.Unmarshal(si.RawContent, &seq); err != nil {
+		return fmt.Errorf("unmarshaling SignerInfo: %w", err)
+	}
+	if n := len(seq); n > 0 && seq[n-1].Class == asn1.ClassContextSpecific && seq[n-1].Tag == 1 {
+		seq = seq[:n-1]
+	}
+
+	attrs, err := asn1.MarshalWithParams(si.UnauthenticatedAttributes, "tag:1")
+	if err != nil {
+		return err
+	}
+	var body []byte
+	for _, v := range seq {
+		body = append(body, v.FullBytes...)
+	}
+	body = append(body, attrs...)
+
+	raw, err := asn1.Marshal(asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSequence, IsCompound: true, Bytes: body})
+	if err != nil {
+		return err
+	}
+	si.RawContent = raw
+	return nil
+}
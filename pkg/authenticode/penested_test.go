@@ -0,0 +1,69 @@
+package authenticode
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/sassoftware/relic/v8/lib/certloader"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testCertificate(t *testing.T) *certloader.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "Test Signer"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	leaf, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &certloader.Certificate{Leaf: leaf, Certificates: []*x509.Certificate{leaf}, PrivateKey: key}
+}
+
+func testSignature(t *testing.T, cert *certloader.Certificate, hash crypto.Hash) []byte {
+	d := hash.New()
+	d.Write([]byte("image"))
+	indirect, err := makePeIndirect(d.Sum(nil), hash, OidSpcPeImageData)
+	require.NoError(t, err)
+	ts, err := signIndirect(context.Background(), indirect, hash, cert, nil)
+	require.NoError(t, err)
+	return ts.Raw
+}
+
+func TestNestSignature(t *testing.T) {
+	cert := testCertificate(t)
+	primary := testSignature(t, cert, crypto.SHA1)
+	nested := testSignature(t, cert, crypto.SHA256)
+
+	blob, err := NestSignature(primary, nested)
+	require.NoError(t, err)
+
+	sigs, err := checkSignatureTree(blob, false)
+	require.NoError(t, err)
+	require.Len(t, sigs, 2)
+	assert.False(t, sigs[0].Nested)
+	assert.Equal(t, crypto.SHA1, sigs[0].ImageHashFunc)
+	assert.True(t, sigs[1].Nested)
+	assert.Equal(t, crypto.SHA256, sigs[1].ImageHashFunc)
+
+	// appending again keeps both earlier signatures
+	blob, err = NestSignature(blob, testSignature(t, cert, crypto.SHA384))
+	require.NoError(t, err)
+	sigs, err = checkSignatureTree(blob, false)
+	require.NoError(t, err)
+	assert.Len(t, sigs, 3)
+}
@@ -39,6 +39,8 @@ type PESignature struct {
 	ImageHashFunc crypto.Hash
 	PageHashes    []byte
 	PageHashFunc  crypto.Hash
+	// Set for signatures nested inside another signature
+	Nested bool
 }
 
 // Extract and verify the signature from a PE/COFF image file. Does not check X509 chains.
@@ -101,22 +103,24 @@ func checkSignatures(blob []byte, image io.ReadSeeker) ([]PESignature, error) {
 		cert := blob[8 : 8+size]
 		blob = blob[end:]
 
-		sig, err := checkSignature(cert)
+		found, err := checkSignatureTree(cert, false)
 		if err != nil {
 			return nil, err
 		}
-		allhashes[sig.ImageHashFunc] = true
-		if len(sig.PageHashes) > 0 {
-			phvalues[sig.PageHashFunc] = sig.PageHashes
-			allhashes[sig.PageHashFunc] = true
-		}
-		sigs = append(sigs, *sig)
-		imageDigest := sig.Indirect.MessageDigest.Digest
-		if existing := values[sig.ImageHashFunc]; existing == nil {
-			values[sig.ImageHashFunc] = imageDigest
-		} else if !hmac.Equal(imageDigest, existing) {
-			// they can't both be right...
-			return nil, fmt.Errorf("digest mismatch: %x != %x", imageDigest, existing)
+		for _, sig := range found {
+			allhashes[sig.ImageHashFunc] = true
+			if len(sig.PageHashes) > 0 {
+				phvalues[sig.PageHashFunc] = sig.PageHashes
+				allhashes[sig.PageHashFunc] = true
+			}
+			sigs = append(sigs, sig)
+			imageDigest := sig.Indirect.MessageDigest.Digest
+			if existing := values[sig.ImageHashFunc]; existing == nil {
+				values[sig.ImageHashFunc] = imageDigest
+			} else if !hmac.Equal(imageDigest, existing) {
+				// they can't both be right...
+				return nil, fmt.Errorf("digest mismatch: %x != %x", imageDigest, existing)
+			}
 		}
 	}
 	if image == nil {
@@ -143,6 +147,56 @@ func checkSignatures(blob []byte, image io.ReadSeeker) ([]PESignature, error) {
 	return sigs, nil
 }
 
+// Check a signature and every signature nested inside it
+func checkSignatureTree(der []byte, nested bool) ([]PESignature, error) {
+	sig, err := checkSignature(der)
+	if err != nil {
+		return nil, err
+	}
+	sig.Nested = nested
+	sigs := []PESignature{*sig}
+
+	blobs, err := nestedSignatures(der)
+	if err != nil {
+		return nil, err
+	}
+	for _, blob := range blobs {
+		found, err := checkSignatureTree(blob, true)
+		if err != nil {
+			return nil, fmt.Errorf("nested signature: %w", err)
+		}
+		sigs = append(sigs, found...)
+	}
+	return sigs, nil
+}
+
+// Return the signatures stored in the szOID_NESTED_SIGNATURE attribute
+func nestedSignatures(der []byte) ([][]byte, error) {
+	psd, err := pkcs7.Unmarshal(der)
+	if err != nil {
+		return nil, err
+	}
+	if len(psd.Content.SignerInfos) == 0 {
+		return nil, nil
+	}
+	var blobs [][]byte
+	for _, attr := range psd.Content.SignerInfos[0].UnauthenticatedAttributes {
+		if !attr.Type.Equal(OidSpcNestedSignature) {
+			continue
+		}
+		rest := attr.Values.Bytes
+		for len(rest) > 0 {
+			var raw asn1.RawValue
+			rest, err = asn1.Unmarshal(rest, &raw)
+			if err != nil {
+				return nil, fmt.Errorf("unmarshaling nested signature: %w", err)
+			}
+			blobs = append(blobs, raw.FullBytes)
+		}
+	}
+	return blobs, nil
+}
+
 func checkSignature(der []byte) (*PESignature, error) {
 	psd, err := pkcs7.Unmarshal(der)
 	if err != nil {
@@ -0,0 +1,195 @@
+package awskms
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/aws/smithy-go"
+	"github.com/ossign/ossign/pkg/internal/leaf"
+	"github.com/ossign/ossign/pkg/sigerr"
+	"github.com/sassoftware/relic/v8/config"
+	"github.com/sassoftware/relic/v8/lib/certloader"
+	"github.com/sassoftware/relic/v8/lib/pkcs9"
+)
+
+type KmsKey struct {
+	kconf *config.KeyConfig
+	cli   *kms.Client
+	keyId string
+	pub   crypto.PublicKey
+	id    []byte
+}
+
+func (k *KmsKey) Config() *config.KeyConfig { return k.kconf }
+func (k *KmsKey) Certificate() []byte       { return nil }
+func (k *KmsKey) GetID() []byte             { return k.id }
+func (k *KmsKey) Public() crypto.PublicKey  { return k.pub }
+func (k *KmsKey) ImportCertificate(cert *x509.Certificate) error {
+	return fmt.Errorf("importing certificate not supported for KmsKey")
+}
+
+// The KMS client is safe for concurrent use
+func (k *KmsKey) SignsConcurrently() bool { return true }
+
+func (k *KmsKey) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return k.SignContext(context.Background(), digest, opts)
+}
+
+func (k *KmsKey) SignContext(ctx context.Context, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	alg, err := k.sigAlgorithm(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := k.cli.Sign(ctx, &kms.SignInput{
+		KeyId:            aws.String(k.keyId),
+		Message:          digest,
+		MessageType:      types.MessageTypeDigest,
+		SigningAlgorithm: alg,
+	})
+	if err != nil {
+		return nil, wrapError("signing digest", err)
+	}
+	// ECDSA signatures are already DER encoded
+	return resp.Signature, nil
+}
+
+// select a KMS signing algorithm based on the public key algorithm and requested hash func
+func (k *KmsKey) sigAlgorithm(opts crypto.SignerOpts) (types.SigningAlgorithmSpec, error) {
+	var alg string
+	switch opts.HashFunc() {
+	case crypto.SHA256:
+		alg = "SHA_256"
+	case crypto.SHA384:
+		alg = "SHA_384"
+	case crypto.SHA512:
+		alg = "SHA_512"
+	default:
+		return "", fmt.Errorf("unsupported digest algorithm %s", opts.HashFunc())
+	}
+	switch k.pub.(type) {
+	case *rsa.PublicKey:
+		if pss, ok := opts.(*rsa.PSSOptions); ok {
+			// KMS always uses a salt as long as the digest
+			if pss.SaltLength != rsa.PSSSaltLengthEqualsHash && pss.SaltLength != rsa.PSSSaltLengthAuto && pss.SaltLength != opts.HashFunc().Size() {
+				return "", fmt.Errorf("unsupported PSS salt length %d", pss.SaltLength)
+			}
+			return types.SigningAlgorithmSpec("RSASSA_PSS_" + alg), nil
+		} else {
+			return types.SigningAlgorithmSpec("RSASSA_PKCS1_V1_5_" + alg), nil
+		}
+	case *ecdsa.PublicKey:
+		return types.SigningAlgorithmSpec("ECDSA_" + alg), nil
+	default:
+		return "", fmt.Errorf("unsupported public key type %T", k.pub)
+	}
+}
+
+func NewKmsKey(opts Options, ctx context.Context, timestamper pkcs9.Timestamper) (*certloader.Certificate, error) {
+	if opts.KeyId == "" {
+		return nil, sigerr.ConfigError{Err: errors.New("no KMS key ID configured")}
+	}
+	if opts.CertificateFile == "" {
+		return nil, sigerr.ConfigError{Err: errors.New("no certificate file configured for the KMS key")}
+	}
+
+	var loadOpts []func(*awsconfig.LoadOptions) error
+	if opts.Region != "" {
+		loadOpts = append(loadOpts, awsconfig.WithRegion(opts.Region))
+	}
+	if opts.Profile != "" {
+		loadOpts = append(loadOpts, awsconfig.WithSharedConfigProfile(opts.Profile))
+	}
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, loadOpts...)
+	if err != nil {
+		return nil, sigerr.CredentialError{Err: fmt.Errorf("loading AWS configuration: %w", err)}
+	}
+
+	cli := kms.NewFromConfig(cfg, func(o *kms.Options) {
+		if opts.Endpoint != "" {
+			o.BaseEndpoint = aws.String(opts.Endpoint)
+		}
+	})
+
+	pubResp, err := cli.GetPublicKey(ctx, &kms.GetPublicKeyInput{KeyId: aws.String(opts.KeyId)})
+	if err != nil {
+		return nil, wrapError("getting public key", err)
+	}
+	if pubResp.KeyUsage != types.KeyUsageTypeSignVerify {
+		return nil, sigerr.ConfigError{Err: fmt.Errorf("KMS key %s cannot be used for signing", opts.KeyId)}
+	}
+	pub, err := x509.ParsePKIXPublicKey(pubResp.PublicKey)
+	if err != nil {
+		return nil, sigerr.RemoteSigningError{Err: fmt.Errorf("parsing public key: %w", err)}
+	}
+
+	blob, err := os.ReadFile(opts.CertificateFile)
+	if err != nil {
+		return nil, sigerr.ConfigError{Err: fmt.Errorf("reading certificate file: %w", err)}
+	}
+	certs, err := certloader.ParseX509Certificates(blob)
+	if err != nil {
+		return nil, sigerr.ConfigError{Err: fmt.Errorf("parsing certificate file: %w", err)}
+	}
+	certs, err = leaf.First(certs, pub, "KMS key")
+	if err != nil {
+		return nil, err
+	}
+
+	key := &KmsKey{
+		kconf: &config.KeyConfig{ID: opts.KeyId, Token: "awsKms"},
+		cli:   cli,
+		keyId: aws.ToString(pubResp.KeyId),
+		pub:   pub,
+		id:    []byte(aws.ToString(pubResp.KeyId)),
+	}
+
+	return &certloader.Certificate{
+		Leaf:         certs[0],
+		Certificates: certs,
+		PrivateKey:   key,
+		KeyName:      opts.KeyId,
+		Timestamper:  timestamper,
+	}, nil
+}
+
+// Sort an error from the AWS SDK into a credential or remote signing failure
+func wrapError(msg string, err error) error {
+	err = fmt.Errorf("%s: %w", msg, err)
+
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "AccessDeniedException", "UnrecognizedClientException", "InvalidSignatureException",
+			"ExpiredTokenException", "IncompleteSignature", "MissingAuthenticationToken":
+			return sigerr.CredentialError{Err: err}
+		}
+	}
+
+	var respErr *awshttp.ResponseError
+	if errors.As(err, &respErr) && (respErr.HTTPStatusCode() == http.StatusUnauthorized || respErr.HTTPStatusCode() == http.StatusForbidden) {
+		return sigerr.CredentialError{Err: err}
+	}
+
+	// the credential chain found nothing or could not refresh
+	var signErr *v4.SigningError
+	if errors.As(err, &signErr) {
+		return sigerr.CredentialError{Err: err}
+	}
+
+	return sigerr.RemoteSigningError{Err: err}
+}
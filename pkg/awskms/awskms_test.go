@@ -0,0 +1,157 @@
+package awskms
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ossign/ossign/pkg/sigerr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testKeyId = "arn:aws:kms:eu-west-1:111122223333:key/1234abcd-12ab-34cd-56ef-1234567890ab"
+
+// A fake KMS endpoint speaking the JSON protocol for GetPublicKey and Sign
+func fakeKms(t *testing.T, key crypto.Signer) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		var req struct {
+			KeyId            string
+			Message          []byte
+			MessageType      string
+			SigningAlgorithm string
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		if req.KeyId != "alias/codesign" && req.KeyId != testKeyId {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"__type": "NotFoundException", "message": "no such key"})
+			return
+		}
+
+		switch r.Header.Get("X-Amz-Target") {
+		case "TrentService.GetPublicKey":
+			der, _ := x509.MarshalPKIXPublicKey(key.Public())
+			json.NewEncoder(w).Encode(map[string]any{
+				"KeyId":     testKeyId,
+				"KeyUsage":  "SIGN_VERIFY",
+				"PublicKey": der,
+			})
+		case "TrentService.Sign":
+			if req.MessageType != "DIGEST" {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]string{"__type": "ValidationException", "message": "expected a digest"})
+				return
+			}
+			var opts crypto.SignerOpts = crypto.SHA256
+			if req.SigningAlgorithm == "RSASSA_PSS_SHA_256" {
+				opts = &rsa.PSSOptions{Hash: crypto.SHA256, SaltLength: rsa.PSSSaltLengthEqualsHash}
+			}
+			sig, err := key.Sign(rand.Reader, req.Message, opts)
+			require.NoError(t, err)
+			json.NewEncoder(w).Encode(map[string]any{
+				"KeyId":            testKeyId,
+				"Signature":        sig,
+				"SigningAlgorithm": req.SigningAlgorithm,
+			})
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+}
+
+func writeCertificate(t *testing.T, key crypto.Signer) string {
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "kms signer"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "chain.pem")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	return path
+}
+
+func setFakeCredentials(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(t.TempDir(), "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "credentials"))
+}
+
+func TestKmsKeyRSA(t *testing.T) {
+	setFakeCredentials(t)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	srv := fakeKms(t, rsaKey)
+	defer srv.Close()
+
+	cert, err := NewKmsKey(Options{
+		KeyId:           "alias/codesign",
+		Region:          "eu-west-1",
+		Endpoint:        srv.URL,
+		CertificateFile: writeCertificate(t, rsaKey),
+	}, context.Background(), nil)
+	require.NoError(t, err)
+	assert.Equal(t, []byte(testKeyId), cert.PrivateKey.(*KmsKey).GetID())
+
+	digest := sha256.Sum256([]byte("hello"))
+	sig, err := cert.Signer().Sign(rand.Reader, digest[:], crypto.SHA256)
+	require.NoError(t, err)
+	assert.NoError(t, rsa.VerifyPKCS1v15(&rsaKey.PublicKey, crypto.SHA256, digest[:], sig))
+
+	pss := &rsa.PSSOptions{Hash: crypto.SHA256, SaltLength: rsa.PSSSaltLengthEqualsHash}
+	sig, err = cert.Signer().Sign(rand.Reader, digest[:], pss)
+	require.NoError(t, err)
+	assert.NoError(t, rsa.VerifyPSS(&rsaKey.PublicKey, crypto.SHA256, digest[:], sig, pss))
+}
+
+func TestKmsKeyECDSA(t *testing.T) {
+	setFakeCredentials(t)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	srv := fakeKms(t, ecKey)
+	defer srv.Close()
+
+	cert, err := NewKmsKey(Options{
+		KeyId:           "alias/codesign",
+		Region:          "eu-west-1",
+		Endpoint:        srv.URL,
+		CertificateFile: writeCertificate(t, ecKey),
+	}, context.Background(), nil)
+	require.NoError(t, err)
+
+	digest := sha256.Sum256([]byte("hello"))
+	sig, err := cert.Signer().Sign(rand.Reader, digest[:], crypto.SHA256)
+	require.NoError(t, err)
+	assert.True(t, ecdsa.VerifyASN1(&ecKey.PublicKey, digest[:], sig))
+
+	// the certificate must belong to the KMS key
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	_, err = NewKmsKey(Options{
+		KeyId:           "alias/codesign",
+		Region:          "eu-west-1",
+		Endpoint:        srv.URL,
+		CertificateFile: writeCertificate(t, otherKey),
+	}, context.Background(), nil)
+	assert.True(t, errors.As(err, &sigerr.ConfigError{}))
+}
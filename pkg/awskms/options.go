@@ -0,0 +1,17 @@
+package awskms
+
+// Options select the KMS key and how to reach it. Credentials come from the
+// standard AWS chain: environment, shared config and SSO, web identity, or
+// the instance and container roles.
+type Options struct {
+	// Key ID, key ARN, alias name ("alias/codesign") or alias ARN
+	KeyId string
+	// Default is taken from AWS_REGION or the shared config
+	Region string
+	// Named profile from the shared config files
+	Profile string
+	// Custom endpoint, e.g. a local KMS emulator
+	Endpoint string
+	// PEM file with the certificate chain
+	CertificateFile string
+}
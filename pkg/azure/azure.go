@@ -9,14 +9,18 @@ import (
 	"crypto/x509"
 	"encoding/json"
 	"encoding/pem"
+	"errors"
 	"fmt"
 	"io"
+	"net/http"
 	"strings"
 
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
 	"github.com/Azure/azure-sdk-for-go/sdk/keyvault/azkeys"
 	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azcertificates"
 	"github.com/go-jose/go-jose/v4"
+	"github.com/ossign/ossign/pkg/sigerr"
 	"github.com/sassoftware/relic/v8/config"
 	"github.com/sassoftware/relic/v8/lib/certloader"
 	"github.com/sassoftware/relic/v8/lib/pkcs7"
@@ -44,6 +48,9 @@ func (k *AzureKey) ImportCertificate(cert *x509.Certificate) error {
 	return fmt.Errorf("importing certificate not supported for AzureKey")
 }
 
+// The Key Vault client is safe for concurrent use
+func (k *AzureKey) SignsConcurrently() bool { return true }
+
 func (k *AzureKey) SignContext(ctx context.Context, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
 	alg, err := k.sigAlgorithm(opts)
 	if err != nil {
@@ -55,7 +62,7 @@ func (k *AzureKey) SignContext(ctx context.Context, digest []byte, opts crypto.S
 		Value:     digest,
 	}, nil)
 	if err != nil {
-		return nil, err
+		return nil, wrapError("signing digest", err)
 	}
 	sig := resp.Result
 	if _, ok := k.pub.(*ecdsa.PublicKey); ok {
@@ -69,6 +76,11 @@ func (k *AzureKey) SignContext(ctx context.Context, digest []byte, opts crypto.S
 	return sig, nil
 }
 
+// Key Vault has no signature algorithms for SHA-1
+func (k *AzureKey) SupportedHashes() []crypto.Hash {
+	return []crypto.Hash{crypto.SHA256, crypto.SHA384, crypto.SHA512}
+}
+
 // select a JOSE signature algorithm based on the public key algorithm and requested hash func
 func (k *AzureKey) sigAlgorithm(opts crypto.SignerOpts) (azkeys.JSONWebKeySignatureAlgorithm, error) {
 	var alg azkeys.JSONWebKeySignatureAlgorithm
@@ -104,20 +116,15 @@ func (k *AzureKey) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) (
 	return k.SignContext(context.Background(), digest, opts)
 }
 
-func NewAzureKey(vaultUrl, tenant, client, secret, certName, certVersion string, ctx context.Context) (*AzureKey, error) {
-	azcr, err := azidentity.NewClientSecretCredential(tenant, client, secret, nil)
-	if err != nil {
-		panic(err)
-	}
-
+func NewAzureKey(vaultUrl string, azcr azcore.TokenCredential, certName, certVersion string, ctx context.Context) (*AzureKey, error) {
 	certCli, err := azcertificates.NewClient(vaultUrl, azcr, nil)
 	if err != nil {
-		panic(err)
+		return nil, sigerr.ConfigError{Err: fmt.Errorf("creating certificate client: %w", err)}
 	}
 
 	cert, err := certCli.GetCertificate(ctx, certName, certVersion, nil)
 	if err != nil {
-		panic(err)
+		return nil, wrapError("getting certificate", err)
 	}
 
 	pemin := cert.CER
@@ -130,13 +137,13 @@ func NewAzureKey(vaultUrl, tenant, client, secret, certName, certVersion string,
 		} else if block != nil && block.Type == "CERTIFICATE" || block != nil && block.Type == "PKCS7" {
 			newcerts, err := parseCertificatesDer(block.Bytes)
 			if err != nil {
-				return nil, err
+				return nil, sigerr.RemoteSigningError{Err: fmt.Errorf("parsing certificate: %w", err)}
 			}
 			certs = append(certs, newcerts.Certificates...)
 		} else {
 			newcerts, err := parseCertificatesDer(pemin)
 			if err != nil {
-				return nil, err
+				return nil, sigerr.RemoteSigningError{Err: fmt.Errorf("parsing certificate: %w", err)}
 			}
 			certs = append(certs, newcerts.Certificates...)
 			break
@@ -145,12 +152,12 @@ func NewAzureKey(vaultUrl, tenant, client, secret, certName, certVersion string,
 
 	keyClient, err := azkeys.NewClient(vaultUrl, azcr, nil)
 	if err != nil {
-		panic(fmt.Errorf("creating key client: %w", err))
+		return nil, sigerr.ConfigError{Err: fmt.Errorf("creating key client: %w", err)}
 	}
 
 	key, err := keyClient.GetKey(ctx, certName, certVersion, nil)
 	if err != nil {
-		panic(fmt.Errorf("getting key: %w", err))
+		return nil, wrapError("getting key", err)
 	}
 
 	// strip off -HSM suffix to get a key type jose will accept
@@ -159,12 +166,12 @@ func NewAzureKey(vaultUrl, tenant, client, secret, certName, certVersion string,
 
 	keyBlob, err := json.Marshal(key.Key)
 	if err != nil {
-		return nil, fmt.Errorf("marshaling public key: %w", err)
+		return nil, sigerr.RemoteSigningError{Err: fmt.Errorf("marshaling public key: %w", err)}
 	}
 
 	var jwk jose.JSONWebKey
 	if err := json.Unmarshal(keyBlob, &jwk); err != nil {
-		return nil, fmt.Errorf("unmarshaling public key: %w", err)
+		return nil, sigerr.RemoteSigningError{Err: fmt.Errorf("unmarshaling public key: %w", err)}
 	}
 
 	return &AzureKey{
@@ -207,6 +214,27 @@ func parseCertificatesDer(der []byte) (*certloader.Certificate, error) {
 	}, nil
 }
 
+// Sort an error from the Azure SDK into a credential or remote signing failure
+func wrapError(msg string, err error) error {
+	err = fmt.Errorf("%s: %w", msg, err)
+
+	if errors.As(err, &sigerr.CredentialError{}) {
+		return sigerr.CredentialError{Err: err}
+	}
+
+	var authErr *azidentity.AuthenticationFailedError
+	if errors.As(err, &authErr) {
+		return sigerr.CredentialError{Err: err}
+	}
+
+	var respErr *azcore.ResponseError
+	if errors.As(err, &respErr) && (respErr.StatusCode == http.StatusUnauthorized || respErr.StatusCode == http.StatusForbidden) {
+		return sigerr.CredentialError{Err: err}
+	}
+
+	return sigerr.RemoteSigningError{Err: err}
+}
+
 func NewKeyConfig(name string, token string) *config.KeyConfig {
 	return &config.KeyConfig{
 		ID:    name,
@@ -3,11 +3,17 @@ package azure
 import (
 	"context"
 	"crypto"
+	"crypto/rsa"
 	"crypto/x509"
+	"errors"
 	"fmt"
+	"io"
+	"log"
 	"strings"
+	"time"
 
-	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/ossign/ossign/pkg/sigerr"
 	"github.com/sassoftware/relic/v8/config"
 	"github.com/sassoftware/relic/v8/lib/certloader"
 	"github.com/sassoftware/relic/v8/lib/pkcs9"
@@ -15,47 +21,49 @@ import (
 	goats "github.com/ossign/go-azure-trusted-signing"
 )
 
-type AzureTrustedKey struct {
-	*goats.AzureTrustedSigning
+type TrustedOptions struct {
+	Region  string
+	Account string
+	Profile string
+	// How long a fetched certificate chain is reused, DefaultChainCacheTTL if zero
+	CacheTTL time.Duration
+	// Fetch the certificate chain on every run
+	NoCache bool
+}
 
-	crtCache []*x509.Certificate
+// The parts of the Artifact Signing client used for signing
+type trustedClient interface {
+	GetCertificateChain(ctx context.Context) ([]*x509.Certificate, error)
+	SignAndWait(ctx context.Context, req goats.SignRequest) (*goats.SignResponse, error)
+}
+
+type AzureTrustedKey struct {
+	cli   trustedClient
+	opts  TrustedOptions
+	cache *chainCache
+	// The certificate handed to the signers. It is shared by concurrent
+	// signing operations and never changes.
+	cert *certloader.Certificate
 }
 
 func (k *AzureTrustedKey) Config() *config.KeyConfig {
 	return NewKeyConfig("azureTrusted", "azureTrusted")
 }
 
-func (k *AzureTrustedKey) Certificate() []byte {
-	if k.crtCache != nil {
-		return k.crtCache[0].Raw
-	}
-	crts, err := k.GetCertificateChain(context.Background())
-	if err != nil {
-		return nil
-	}
+func (k *AzureTrustedKey) Certificate() []byte      { return k.cert.Leaf.Raw }
+func (k *AzureTrustedKey) GetID() []byte            { return []byte(k.cert.Leaf.SerialNumber.String()) }
+func (k *AzureTrustedKey) Public() crypto.PublicKey { return k.cert.Leaf.PublicKey }
 
-	k.crtCache = crts
-
-	return crts[0].Raw
+func (k *AzureTrustedKey) ImportCertificate(cert *x509.Certificate) error {
+	return fmt.Errorf("importing certificate not supported for AzureTrustedKey")
 }
 
-func (k *AzureTrustedKey) GetID() []byte {
-	if k.crtCache != nil && len(k.crtCache) > 0 {
-		return []byte(k.crtCache[0].SerialNumber.String())
-	}
-
-	crt, err := k.GetCertificateChain(context.Background())
-	if err != nil || len(crt) == 0 {
-		return nil
-	}
-
-	k.crtCache = crt
+// The service signs each digest separately, and a rotated certificate only
+// leads to an error
+func (k *AzureTrustedKey) SignsConcurrently() bool { return true }
 
-	return []byte(crt[0].SerialNumber.String())
-}
-
-func (k *AzureTrustedKey) ImportCertificate(cert *x509.Certificate) error {
-	return fmt.Errorf("importing certificate not supported for AzureTrustedKey")
+func (k *AzureTrustedKey) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return k.SignContext(context.Background(), digest, opts)
 }
 
 func (k *AzureTrustedKey) SignContext(ctx context.Context, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
@@ -63,36 +71,94 @@ func (k *AzureTrustedKey) SignContext(ctx context.Context, digest []byte, opts c
 	if goats.FromHashFunc[hfunc] == "" {
 		return nil, fmt.Errorf("unsupported digest algorithm %s", hfunc)
 	}
+	if _, ok := opts.(*rsa.PSSOptions); ok {
+		return nil, fmt.Errorf("Artifact Signing does not support RSA-PSS signatures")
+	}
 
-	resp, err := k.SignAndWait(ctx, goats.SignRequest{
+	resp, err := k.cli.SignAndWait(ctx, goats.SignRequest{
 		Digest:             digest,
 		SignatureAlgorithm: goats.FromHashFunc[hfunc],
 	})
 	if err != nil {
-		return nil, err
+		return nil, wrapError("signing digest", err)
+	}
+
+	// The service signs with the key of its current certificate, which is not
+	// the one embedded in the signature if the certificate rotated since the
+	// chain was fetched. Only RSA signatures are checked, as Artifact Signing
+	// only issues RSA certificates.
+	pub, ok := k.cert.Leaf.PublicKey.(*rsa.PublicKey)
+	if ok && rsa.VerifyPKCS1v15(pub, opts.HashFunc(), digest, resp.Signature) != nil {
+		return nil, k.rotated()
 	}
 
 	return resp.Signature, nil
 }
 
-func NewAzureTrustedKey(region, tenant, client, secret, account, profile string, ctx context.Context, timestamper pkcs9.Timestamper) (*certloader.Certificate, error) {
-	azcr, err := azidentity.NewClientSecretCredential(tenant, client, secret, nil)
+// Discard the stale cached chain, so that the next run fetches the current one
+// and checks it like any other certificate
+func (k *AzureTrustedKey) rotated() error {
+	if k.cache != nil {
+		if err := k.cache.remove(k.opts.Region, k.opts.Account, k.opts.Profile); err != nil {
+			log.Printf("Could not remove cached certificate chain: %v", err)
+		}
+	}
+	return sigerr.RemoteSigningError{Err: fmt.Errorf("the signature for profile %s does not match certificate %s, which has likely been rotated; sign again",
+		k.opts.Profile, k.cert.Leaf.SerialNumber)}
+}
+
+// Get the certificate chain from the service and remember it in the cache
+func (k *AzureTrustedKey) fetchChain(ctx context.Context) ([]*x509.Certificate, error) {
+	certs, err := k.cli.GetCertificateChain(ctx)
 	if err != nil {
-		panic(err)
+		return nil, wrapError("getting certificate chain", err)
+	}
+	if len(certs) == 0 {
+		return nil, sigerr.RemoteSigningError{Err: fmt.Errorf("no certificates returned for profile %s", k.opts.Profile)}
+	}
+	if k.cache != nil {
+		if err := k.cache.store(k.opts.Region, k.opts.Account, k.opts.Profile, certs); err != nil {
+			log.Printf("Could not cache certificate chain: %v", err)
+		}
+	}
+	return certs, nil
+}
+
+func NewAzureTrustedKey(opts TrustedOptions, azcr azcore.TokenCredential, ctx context.Context, timestamper pkcs9.Timestamper) (*certloader.Certificate, error) {
+	if azcr == nil {
+		return nil, sigerr.CredentialError{Err: errors.New("no Azure credential for Artifact Signing")}
+	}
+	if opts.Region == "" || opts.Account == "" || opts.Profile == "" {
+		return nil, sigerr.ConfigError{Err: errors.New("Artifact Signing needs a region, account and certificate profile")}
 	}
 
-	crtclient := goats.NewClient(goats.AzureTrustedSigningRegion(region), azcr, account, profile)
+	crtclient := goats.NewClient(goats.AzureTrustedSigningRegion(opts.Region), azcr, opts.Account, opts.Profile)
+	return newAzureTrustedKey(crtclient, opts, ctx, timestamper)
+}
 
-	certs, err := crtclient.GetCertificateChain(ctx)
-	if err != nil {
-		return nil, err
+func newAzureTrustedKey(cli trustedClient, opts TrustedOptions, ctx context.Context, timestamper pkcs9.Timestamper) (*certloader.Certificate, error) {
+	key := &AzureTrustedKey{cli: cli, opts: opts}
+	if !opts.NoCache {
+		key.cache = newChainCache(opts.CacheTTL)
 	}
 
-	return &certloader.Certificate{
+	var certs []*x509.Certificate
+	if key.cache != nil {
+		certs = key.cache.load(opts.Region, opts.Account, opts.Profile)
+	}
+	if certs == nil {
+		var err error
+		if certs, err = key.fetchChain(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	key.cert = &certloader.Certificate{
 		Certificates: certs,
 		Leaf:         certs[0],
-		PrivateKey:   crtclient,
-		KeyName:      profile,
+		PrivateKey:   key,
+		KeyName:      opts.Profile,
 		Timestamper:  timestamper,
-	}, nil
+	}
+	return key.cert, nil
 }
@@ -0,0 +1,135 @@
+package azure
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ossign/ossign/pkg/sigerr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	goats "github.com/ossign/go-azure-trusted-signing"
+)
+
+// A fake Artifact Signing profile whose certificate can be rotated
+type fakeTrusted struct {
+	key     *rsa.PrivateKey
+	cert    *x509.Certificate
+	fetches int
+	err     error
+}
+
+func (f *fakeTrusted) rotate(t *testing.T, notAfter time.Time) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "trusted signer"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	f.cert, err = x509.ParseCertificate(der)
+	require.NoError(t, err)
+	f.key = key
+}
+
+func (f *fakeTrusted) GetCertificateChain(ctx context.Context) ([]*x509.Certificate, error) {
+	f.fetches++
+	if f.err != nil {
+		return nil, f.err
+	}
+	return []*x509.Certificate{f.cert}, nil
+}
+
+func (f *fakeTrusted) SignAndWait(ctx context.Context, req goats.SignRequest) (*goats.SignResponse, error) {
+	sig, err := rsa.SignPKCS1v15(rand.Reader, f.key, crypto.SHA256, req.Digest)
+	return &goats.SignResponse{Signature: sig}, err
+}
+
+var trustedOpts = TrustedOptions{Region: "neu", Account: "account", Profile: "profile"}
+
+func TestAzureTrustedKeyCache(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	fake := &fakeTrusted{}
+	fake.rotate(t, time.Now().Add(72*time.Hour))
+
+	_, err := newAzureTrustedKey(fake, trustedOpts, context.Background(), nil)
+	require.NoError(t, err)
+	cert, err := newAzureTrustedKey(fake, trustedOpts, context.Background(), nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, fake.fetches)
+	assert.Equal(t, fake.cert.Raw, cert.Leaf.Raw)
+
+	// other profiles have their own entry
+	other := trustedOpts
+	other.Profile = "other"
+	_, err = newAzureTrustedKey(fake, other, context.Background(), nil)
+	require.NoError(t, err)
+	assert.Equal(t, 2, fake.fetches)
+
+	noCache := trustedOpts
+	noCache.NoCache = true
+	_, err = newAzureTrustedKey(fake, noCache, context.Background(), nil)
+	require.NoError(t, err)
+	assert.Equal(t, 3, fake.fetches)
+}
+
+func TestAzureTrustedKeyExpiringCache(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	fake := &fakeTrusted{}
+	fake.rotate(t, time.Now().Add(12*time.Hour))
+
+	_, err := newAzureTrustedKey(fake, trustedOpts, context.Background(), nil)
+	require.NoError(t, err)
+	_, err = newAzureTrustedKey(fake, trustedOpts, context.Background(), nil)
+	require.NoError(t, err)
+	assert.Equal(t, 2, fake.fetches)
+}
+
+func TestAzureTrustedKeyRotated(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	fake := &fakeTrusted{}
+	fake.rotate(t, time.Now().Add(72*time.Hour))
+	cert, err := newAzureTrustedKey(fake, trustedOpts, context.Background(), nil)
+	require.NoError(t, err)
+
+	digest := sha256.Sum256([]byte("hello"))
+	sig, err := cert.Signer().Sign(rand.Reader, digest[:], crypto.SHA256)
+	require.NoError(t, err)
+	assert.NoError(t, rsa.VerifyPKCS1v15(cert.Leaf.PublicKey.(*rsa.PublicKey), crypto.SHA256, digest[:], sig))
+
+	fake.rotate(t, time.Now().Add(72*time.Hour))
+	_, err = cert.Signer().Sign(rand.Reader, digest[:], crypto.SHA256)
+	require.Error(t, err)
+	assert.True(t, errors.As(err, &sigerr.RemoteSigningError{}))
+	assert.Contains(t, err.Error(), "rotated")
+
+	// the certificate of this run stays as it is, and the next run fetches
+	// the new one
+	assert.NotEqual(t, fake.cert.Raw, cert.Leaf.Raw)
+	next, err := newAzureTrustedKey(fake, trustedOpts, context.Background(), nil)
+	require.NoError(t, err)
+	assert.Equal(t, fake.cert.Raw, next.Leaf.Raw)
+	assert.Equal(t, 2, fake.fetches)
+}
+
+func TestAzureTrustedKeyErrors(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	fake := &fakeTrusted{err: sigerr.CredentialError{Err: errors.New("no token")}}
+	_, err := newAzureTrustedKey(fake, trustedOpts, context.Background(), nil)
+	assert.True(t, errors.As(err, &sigerr.CredentialError{}))
+
+	_, err = NewAzureTrustedKey(trustedOpts, nil, context.Background(), nil)
+	assert.True(t, errors.As(err, &sigerr.CredentialError{}))
+}
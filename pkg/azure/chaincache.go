@@ -0,0 +1,109 @@
+package azure
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Artifact Signing certificates are valid for three days and replaced daily, so
+// cached chains are only used for a few hours and never close to their expiry
+const (
+	DefaultChainCacheTTL = 6 * time.Hour
+	chainExpiryMargin    = 24 * time.Hour
+)
+
+// An on-disk cache of certificate chains, one file per account and profile
+type chainCache struct {
+	dir string
+	ttl time.Duration
+	now func() time.Time
+}
+
+// Cache in the user's cache directory, or nil if there is none
+func newChainCache(ttl time.Duration) *chainCache {
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return nil
+	}
+	if ttl <= 0 {
+		ttl = DefaultChainCacheTTL
+	}
+	return &chainCache{dir: filepath.Join(base, "ossign", "azure-trusted"), ttl: ttl, now: time.Now}
+}
+
+func (c *chainCache) path(region, account, profile string) string {
+	sum := sha256.Sum256([]byte(region + "/" + account + "/" + profile))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:16])+".pem")
+}
+
+// Return the cached chain, or nil if there is none or it is too old
+func (c *chainCache) load(region, account, profile string) []*x509.Certificate {
+	path := c.path(region, account, profile)
+	info, err := os.Stat(path)
+	if err != nil || c.now().Sub(info.ModTime()) > c.ttl {
+		return nil
+	}
+	blob, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, blob = pem.Decode(blob)
+		if block == nil {
+			break
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 || c.now().Add(chainExpiryMargin).After(certs[0].NotAfter) {
+		return nil
+	}
+	return certs
+}
+
+func (c *chainCache) store(region, account, profile string, certs []*x509.Certificate) error {
+	var buf bytes.Buffer
+	for _, cert := range certs {
+		pem.Encode(&buf, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	}
+	if err := os.MkdirAll(c.dir, 0o700); err != nil {
+		return fmt.Errorf("creating certificate cache: %w", err)
+	}
+	// write and rename so that concurrent runs never read half a chain
+	tmp, err := os.CreateTemp(c.dir, ".chain-*")
+	if err != nil {
+		return fmt.Errorf("writing certificate cache: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing certificate cache: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("writing certificate cache: %w", err)
+	}
+	return os.Rename(tmp.Name(), c.path(region, account, profile))
+}
+
+func (c *chainCache) remove(region, account, profile string) error {
+	err := os.Remove(c.path(region, account, profile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,165 @@
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/ossign/ossign/pkg/sigerr"
+)
+
+type AuthMethod string
+
+const (
+	// Try environment, workload identity, managed identity and the Azure CLI in turn
+	AuthDefault           AuthMethod = "default"
+	AuthClientSecret      AuthMethod = "clientSecret"
+	AuthClientCertificate AuthMethod = "clientCertificate"
+	AuthManagedIdentity   AuthMethod = "managedIdentity"
+	AuthAzureCli          AuthMethod = "azureCli"
+	AuthEnvironment       AuthMethod = "environment"
+	AuthWorkloadIdentity  AuthMethod = "workloadIdentity"
+)
+
+// Audience of federated tokens exchanged with Entra ID
+const federatedAudience = "api://AzureADTokenExchange"
+
+// Settings for authenticating to Azure. Which ones are used depends on the method.
+type CredentialOptions struct {
+	Method   AuthMethod
+	TenantId string
+	ClientId string
+
+	ClientSecret string
+	// PEM or PKCS#12 file with the client certificate and its private key
+	ClientCertificate         string
+	ClientCertificatePassword string
+	// File with a federated OIDC token. Without it, the AZURE_FEDERATED_TOKEN_FILE
+	// environment variable or the GitHub Actions OIDC token is used.
+	FederatedTokenFile string
+}
+
+// Create the credential for the configured method. Without a method, a client
+// secret is used if one is given and the DefaultAzureCredential chain otherwise.
+func NewCredential(opts CredentialOptions) (azcore.TokenCredential, error) {
+	method := opts.Method
+	if method == "" {
+		if opts.ClientSecret != "" {
+			method = AuthClientSecret
+		} else {
+			method = AuthDefault
+		}
+	}
+
+	var cred azcore.TokenCredential
+	var err error
+	switch method {
+	case AuthDefault:
+		cred, err = azidentity.NewDefaultAzureCredential(&azidentity.DefaultAzureCredentialOptions{TenantID: opts.TenantId})
+	case AuthClientSecret:
+		cred, err = azidentity.NewClientSecretCredential(opts.TenantId, opts.ClientId, opts.ClientSecret, nil)
+	case AuthClientCertificate:
+		blob, rerr := os.ReadFile(opts.ClientCertificate)
+		if rerr != nil {
+			return nil, sigerr.ConfigError{Err: fmt.Errorf("reading client certificate: %w", rerr)}
+		}
+		var password []byte
+		if opts.ClientCertificatePassword != "" {
+			password = []byte(opts.ClientCertificatePassword)
+		}
+		certs, key, perr := azidentity.ParseCertificates(blob, password)
+		if perr != nil {
+			return nil, sigerr.ConfigError{Err: fmt.Errorf("parsing client certificate: %w", perr)}
+		}
+		cred, err = azidentity.NewClientCertificateCredential(opts.TenantId, opts.ClientId, certs, key, nil)
+	case AuthManagedIdentity:
+		miOpts := &azidentity.ManagedIdentityCredentialOptions{}
+		if opts.ClientId != "" {
+			miOpts.ID = azidentity.ClientID(opts.ClientId)
+		}
+		cred, err = azidentity.NewManagedIdentityCredential(miOpts)
+	case AuthAzureCli:
+		cred, err = azidentity.NewAzureCLICredential(&azidentity.AzureCLICredentialOptions{TenantID: opts.TenantId})
+	case AuthEnvironment:
+		cred, err = azidentity.NewEnvironmentCredential(nil)
+	case AuthWorkloadIdentity:
+		tokenFile := opts.FederatedTokenFile
+		if tokenFile == "" {
+			tokenFile = os.Getenv("AZURE_FEDERATED_TOKEN_FILE")
+		}
+		if tokenFile == "" && os.Getenv("ACTIONS_ID_TOKEN_REQUEST_URL") != "" {
+			cred, err = azidentity.NewClientAssertionCredential(opts.TenantId, opts.ClientId, githubActionsToken, nil)
+		} else {
+			cred, err = azidentity.NewWorkloadIdentityCredential(&azidentity.WorkloadIdentityCredentialOptions{
+				TenantID:      opts.TenantId,
+				ClientID:      opts.ClientId,
+				TokenFilePath: tokenFile,
+			})
+		}
+	default:
+		return nil, sigerr.ConfigError{Err: fmt.Errorf("unknown Azure auth method %q", method)}
+	}
+	if err != nil {
+		return nil, sigerr.CredentialError{Err: fmt.Errorf("creating %s credential: %w", method, err)}
+	}
+	return credential{cred}, nil
+}
+
+// Marks every failure to get a token as a credential error, as the chained
+// credentials do not return a common error type
+type credential struct {
+	azcore.TokenCredential
+}
+
+func (c credential) GetToken(ctx context.Context, opts policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	token, err := c.TokenCredential.GetToken(ctx, opts)
+	if err != nil {
+		return token, sigerr.CredentialError{Err: err}
+	}
+	return token, nil
+}
+
+// Request an OIDC token for Entra ID from the GitHub Actions runner. The job
+// needs the "id-token: write" permission.
+func githubActionsToken(ctx context.Context) (string, error) {
+	reqUrl, err := url.Parse(os.Getenv("ACTIONS_ID_TOKEN_REQUEST_URL"))
+	if err != nil {
+		return "", fmt.Errorf("parsing ACTIONS_ID_TOKEN_REQUEST_URL: %w", err)
+	}
+	query := reqUrl.Query()
+	query.Set("audience", federatedAudience)
+	reqUrl.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqUrl.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+os.Getenv("ACTIONS_ID_TOKEN_REQUEST_TOKEN"))
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("requesting GitHub Actions token: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("requesting GitHub Actions token: %s", resp.Status)
+	}
+
+	var token struct {
+		Value string `json:"value"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("decoding GitHub Actions token: %w", err)
+	}
+	if token.Value == "" {
+		return "", errors.New("GitHub Actions returned an empty token")
+	}
+	return token.Value, nil
+}
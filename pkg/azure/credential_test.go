@@ -0,0 +1,47 @@
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ossign/ossign/pkg/sigerr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewCredential(t *testing.T) {
+	_, err := NewCredential(CredentialOptions{Method: "password"})
+	assert.True(t, errors.As(err, &sigerr.ConfigError{}))
+
+	_, err = NewCredential(CredentialOptions{Method: AuthClientCertificate, ClientCertificate: "/nonexistent.pem"})
+	assert.True(t, errors.As(err, &sigerr.ConfigError{}))
+
+	cred, err := NewCredential(CredentialOptions{TenantId: "tenant", ClientId: "client", ClientSecret: "secret"})
+	require.NoError(t, err)
+	assert.NotNil(t, cred)
+}
+
+func TestGithubActionsToken(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer request-token" || r.URL.Query().Get("audience") != federatedAudience {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"value": "oidc-token"})
+	}))
+	defer srv.Close()
+
+	t.Setenv("ACTIONS_ID_TOKEN_REQUEST_URL", srv.URL+"/token?api-version=2.0")
+	t.Setenv("ACTIONS_ID_TOKEN_REQUEST_TOKEN", "request-token")
+	token, err := githubActionsToken(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "oidc-token", token)
+
+	t.Setenv("ACTIONS_ID_TOKEN_REQUEST_TOKEN", "wrong")
+	_, err = githubActionsToken(context.Background())
+	assert.Error(t, err)
+}
@@ -0,0 +1,250 @@
+package gcpkms
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"strings"
+
+	kms "cloud.google.com/go/kms/apiv1"
+	"cloud.google.com/go/kms/apiv1/kmspb"
+	secretmanager "cloud.google.com/go/secretmanager/apiv1"
+	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+	"github.com/ossign/ossign/pkg/internal/leaf"
+	"github.com/ossign/ossign/pkg/sigerr"
+	"github.com/sassoftware/relic/v8/config"
+	"github.com/sassoftware/relic/v8/lib/certloader"
+	"github.com/sassoftware/relic/v8/lib/pkcs9"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+type KmsKey struct {
+	kconf *config.KeyConfig
+	cli   *kms.KeyManagementClient
+	name  string
+	pub   crypto.PublicKey
+	// Cloud KMS fixes the digest and padding in the key algorithm
+	hash crypto.Hash
+	pss  bool
+}
+
+func (k *KmsKey) Config() *config.KeyConfig { return k.kconf }
+func (k *KmsKey) Certificate() []byte       { return nil }
+func (k *KmsKey) GetID() []byte             { return []byte(k.name) }
+func (k *KmsKey) Public() crypto.PublicKey  { return k.pub }
+func (k *KmsKey) ImportCertificate(cert *x509.Certificate) error {
+	return fmt.Errorf("importing certificate not supported for KmsKey")
+}
+
+// The KMS client is safe for concurrent use
+func (k *KmsKey) SignsConcurrently() bool { return true }
+
+// The digest is part of the algorithm of the key version
+func (k *KmsKey) SupportedHashes() []crypto.Hash { return []crypto.Hash{k.hash} }
+
+// Close the connection to Cloud KMS
+func (k *KmsKey) Close() error { return k.cli.Close() }
+
+func (k *KmsKey) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return k.SignContext(context.Background(), digest, opts)
+}
+
+func (k *KmsKey) SignContext(ctx context.Context, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if opts.HashFunc() != k.hash {
+		return nil, fmt.Errorf("key %s only signs %s digests, not %s", k.name, k.hash, opts.HashFunc())
+	}
+	if _, isPss := opts.(*rsa.PSSOptions); isPss != k.pss {
+		return nil, fmt.Errorf("key %s does not support the requested RSA padding", k.name)
+	}
+
+	req := &kmspb.AsymmetricSignRequest{
+		Name:         k.name,
+		Digest:       &kmspb.Digest{},
+		DigestCrc32C: wrapperspb.Int64(int64(crc32.Checksum(digest, crc32c))),
+	}
+	switch k.hash {
+	case crypto.SHA256:
+		req.Digest.Digest = &kmspb.Digest_Sha256{Sha256: digest}
+	case crypto.SHA384:
+		req.Digest.Digest = &kmspb.Digest_Sha384{Sha384: digest}
+	case crypto.SHA512:
+		req.Digest.Digest = &kmspb.Digest_Sha512{Sha512: digest}
+	}
+
+	resp, err := k.cli.AsymmetricSign(ctx, req)
+	if err != nil {
+		return nil, wrapError("signing digest", err)
+	}
+	// protect against corruption on the way to and from KMS
+	if !resp.VerifiedDigestCrc32C {
+		return nil, sigerr.RemoteSigningError{Err: errors.New("KMS did not verify the digest checksum")}
+	}
+	if resp.SignatureCrc32C == nil || int64(crc32.Checksum(resp.Signature, crc32c)) != resp.SignatureCrc32C.Value {
+		return nil, sigerr.RemoteSigningError{Err: errors.New("signature checksum mismatch")}
+	}
+	// ECDSA signatures are already DER encoded
+	return resp.Signature, nil
+}
+
+// Get the digest and padding from a key algorithm such as RSA_SIGN_PSS_3072_SHA256
+func parseAlgorithm(alg kmspb.CryptoKeyVersion_CryptoKeyVersionAlgorithm) (hash crypto.Hash, pss bool, err error) {
+	name := alg.String()
+	switch {
+	case strings.HasPrefix(name, "RSA_SIGN_PSS_"):
+		pss = true
+	case strings.HasPrefix(name, "RSA_SIGN_PKCS1_"), strings.HasPrefix(name, "EC_SIGN_P"):
+	default:
+		return 0, false, fmt.Errorf("unsupported key algorithm %s", name)
+	}
+	switch {
+	case strings.HasSuffix(name, "_SHA256"):
+		hash = crypto.SHA256
+	case strings.HasSuffix(name, "_SHA384"):
+		hash = crypto.SHA384
+	case strings.HasSuffix(name, "_SHA512"):
+		hash = crypto.SHA512
+	default:
+		return 0, false, fmt.Errorf("unsupported key algorithm %s", name)
+	}
+	return hash, pss, nil
+}
+
+func clientOptions(opts Options, endpoint string) []option.ClientOption {
+	var copts []option.ClientOption
+	if endpoint != "" {
+		copts = append(copts, option.WithEndpoint(endpoint))
+	}
+	if opts.Insecure {
+		copts = append(copts,
+			option.WithoutAuthentication(),
+			option.WithGRPCDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())))
+	} else if opts.CredentialsJSON != "" {
+		copts = append(copts, option.WithCredentialsJSON([]byte(opts.CredentialsJSON)))
+	} else if opts.CredentialsFile != "" {
+		copts = append(copts, option.WithCredentialsFile(opts.CredentialsFile))
+	}
+	return copts
+}
+
+func NewKmsKey(opts Options, ctx context.Context, timestamper pkcs9.Timestamper) (*certloader.Certificate, error) {
+	if opts.KeyName == "" {
+		return nil, sigerr.ConfigError{Err: errors.New("no Cloud KMS key version configured")}
+	}
+
+	cli, err := kms.NewKeyManagementClient(ctx, clientOptions(opts, opts.Endpoint)...)
+	if err != nil {
+		return nil, sigerr.CredentialError{Err: fmt.Errorf("creating Cloud KMS client: %w", err)}
+	}
+	cert, err := loadKey(cli, opts, ctx, timestamper)
+	if err != nil {
+		cli.Close()
+		return nil, err
+	}
+	return cert, nil
+}
+
+func loadKey(cli *kms.KeyManagementClient, opts Options, ctx context.Context, timestamper pkcs9.Timestamper) (*certloader.Certificate, error) {
+	resp, err := cli.GetPublicKey(ctx, &kmspb.GetPublicKeyRequest{Name: opts.KeyName})
+	if err != nil {
+		return nil, wrapError("getting public key", err)
+	}
+	hash, pss, err := parseAlgorithm(resp.Algorithm)
+	if err != nil {
+		return nil, sigerr.ConfigError{Err: err}
+	}
+	block, _ := pem.Decode([]byte(resp.Pem))
+	if block == nil {
+		return nil, sigerr.RemoteSigningError{Err: errors.New("no public key returned by Cloud KMS")}
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, sigerr.RemoteSigningError{Err: fmt.Errorf("parsing public key: %w", err)}
+	}
+	switch pub.(type) {
+	case *rsa.PublicKey, *ecdsa.PublicKey:
+	default:
+		return nil, sigerr.ConfigError{Err: fmt.Errorf("unsupported public key type %T", pub)}
+	}
+
+	blob, err := readCertificates(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	certs, err := certloader.ParseX509Certificates(blob)
+	if err != nil {
+		return nil, sigerr.ConfigError{Err: fmt.Errorf("parsing certificate: %w", err)}
+	}
+	certs, err = leaf.First(certs, pub, "Cloud KMS key")
+	if err != nil {
+		return nil, err
+	}
+
+	key := &KmsKey{
+		kconf: &config.KeyConfig{ID: opts.KeyName, Token: "gcpKms"},
+		cli:   cli,
+		name:  opts.KeyName,
+		pub:   pub,
+		hash:  hash,
+		pss:   pss,
+	}
+
+	return &certloader.Certificate{
+		Leaf:         certs[0],
+		Certificates: certs,
+		PrivateKey:   key,
+		KeyName:      opts.KeyName,
+		Timestamper:  timestamper,
+	}, nil
+}
+
+// Read the PEM certificate chain from a file or Secret Manager
+func readCertificates(ctx context.Context, opts Options) ([]byte, error) {
+	switch {
+	case opts.CertificateFile != "":
+		blob, err := os.ReadFile(opts.CertificateFile)
+		if err != nil {
+			return nil, sigerr.ConfigError{Err: fmt.Errorf("reading certificate file: %w", err)}
+		}
+		return blob, nil
+	case opts.CertificateSecret != "":
+		cli, err := secretmanager.NewClient(ctx, clientOptions(opts, opts.SecretEndpoint)...)
+		if err != nil {
+			return nil, sigerr.CredentialError{Err: fmt.Errorf("creating Secret Manager client: %w", err)}
+		}
+		defer cli.Close()
+
+		resp, err := cli.AccessSecretVersion(ctx, &secretmanagerpb.AccessSecretVersionRequest{Name: opts.CertificateSecret})
+		if err != nil {
+			return nil, wrapError("reading certificate secret", err)
+		}
+		return resp.Payload.Data, nil
+	default:
+		return nil, sigerr.ConfigError{Err: errors.New("no certificate file or secret configured for the Cloud KMS key")}
+	}
+}
+
+// Sort a gRPC error into a credential or remote signing failure
+func wrapError(msg string, err error) error {
+	err = fmt.Errorf("%s: %w", msg, err)
+
+	switch status.Code(err) {
+	case codes.Unauthenticated, codes.PermissionDenied:
+		return sigerr.CredentialError{Err: err}
+	}
+	return sigerr.RemoteSigningError{Err: err}
+}
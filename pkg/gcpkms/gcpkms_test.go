@@ -0,0 +1,166 @@
+package gcpkms
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"hash/crc32"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/kms/apiv1/kmspb"
+	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+const testKeyName = "projects/p/locations/global/keyRings/r/cryptoKeys/codesign/cryptoKeyVersions/1"
+
+// A fake Cloud KMS and Secret Manager serving one key and its certificate
+type fakeGcp struct {
+	kmspb.UnimplementedKeyManagementServiceServer
+	secretmanagerpb.UnimplementedSecretManagerServiceServer
+
+	key     crypto.Signer
+	alg     kmspb.CryptoKeyVersion_CryptoKeyVersionAlgorithm
+	certPEM []byte
+}
+
+func (f *fakeGcp) GetPublicKey(ctx context.Context, req *kmspb.GetPublicKeyRequest) (*kmspb.PublicKey, error) {
+	if req.Name != testKeyName {
+		return nil, status.Error(codes.NotFound, "no such key")
+	}
+	der, _ := x509.MarshalPKIXPublicKey(f.key.Public())
+	return &kmspb.PublicKey{
+		Name:      req.Name,
+		Algorithm: f.alg,
+		Pem:       string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})),
+	}, nil
+}
+
+func (f *fakeGcp) AsymmetricSign(ctx context.Context, req *kmspb.AsymmetricSignRequest) (*kmspb.AsymmetricSignResponse, error) {
+	digest := req.Digest.GetSha256()
+	if int64(crc32.Checksum(digest, crc32c)) != req.DigestCrc32C.GetValue() {
+		return nil, status.Error(codes.InvalidArgument, "checksum mismatch")
+	}
+	var opts crypto.SignerOpts = crypto.SHA256
+	if f.alg == kmspb.CryptoKeyVersion_RSA_SIGN_PSS_2048_SHA256 {
+		opts = &rsa.PSSOptions{Hash: crypto.SHA256, SaltLength: rsa.PSSSaltLengthEqualsHash}
+	}
+	sig, err := f.key.Sign(rand.Reader, digest, opts)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &kmspb.AsymmetricSignResponse{
+		Signature:            sig,
+		SignatureCrc32C:      wrapperspb.Int64(int64(crc32.Checksum(sig, crc32c))),
+		VerifiedDigestCrc32C: true,
+		Name:                 req.Name,
+	}, nil
+}
+
+func (f *fakeGcp) AccessSecretVersion(ctx context.Context, req *secretmanagerpb.AccessSecretVersionRequest) (*secretmanagerpb.AccessSecretVersionResponse, error) {
+	if req.Name != "projects/p/secrets/codesign-chain/versions/latest" {
+		return nil, status.Error(codes.PermissionDenied, "permission denied")
+	}
+	return &secretmanagerpb.AccessSecretVersionResponse{
+		Name:    req.Name,
+		Payload: &secretmanagerpb.SecretPayload{Data: f.certPEM},
+	}, nil
+}
+
+func startFakeGcp(t *testing.T, key crypto.Signer, alg kmspb.CryptoKeyVersion_CryptoKeyVersionAlgorithm) string {
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "kms signer"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	require.NoError(t, err)
+
+	fake := &fakeGcp{key: key, alg: alg, certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+	srv := grpc.NewServer()
+	kmspb.RegisterKeyManagementServiceServer(srv, fake)
+	secretmanagerpb.RegisterSecretManagerServiceServer(srv, fake)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+	return lis.Addr().String()
+}
+
+func TestKmsKeyPSS(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	addr := startFakeGcp(t, rsaKey, kmspb.CryptoKeyVersion_RSA_SIGN_PSS_2048_SHA256)
+
+	cert, err := NewKmsKey(Options{
+		KeyName:           testKeyName,
+		Endpoint:          addr,
+		SecretEndpoint:    addr,
+		Insecure:          true,
+		CertificateSecret: "projects/p/secrets/codesign-chain/versions/latest",
+	}, context.Background(), nil)
+	require.NoError(t, err)
+	assert.Equal(t, []crypto.Hash{crypto.SHA256}, cert.PrivateKey.(*KmsKey).SupportedHashes())
+
+	digest := sha256.Sum256([]byte("hello"))
+	pss := &rsa.PSSOptions{Hash: crypto.SHA256, SaltLength: rsa.PSSSaltLengthEqualsHash}
+	sig, err := cert.Signer().Sign(rand.Reader, digest[:], pss)
+	require.NoError(t, err)
+	assert.NoError(t, rsa.VerifyPSS(&rsaKey.PublicKey, crypto.SHA256, digest[:], sig, pss))
+
+	// the key algorithm fixes the padding
+	_, err = cert.Signer().Sign(rand.Reader, digest[:], crypto.SHA256)
+	assert.Error(t, err)
+
+	assert.NoError(t, cert.PrivateKey.(*KmsKey).Close())
+}
+
+func TestKmsKeyECDSA(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	addr := startFakeGcp(t, ecKey, kmspb.CryptoKeyVersion_EC_SIGN_P256_SHA256)
+
+	cert, err := NewKmsKey(Options{
+		KeyName:           testKeyName,
+		Endpoint:          addr,
+		SecretEndpoint:    addr,
+		Insecure:          true,
+		CertificateSecret: "projects/p/secrets/codesign-chain/versions/latest",
+	}, context.Background(), nil)
+	require.NoError(t, err)
+
+	digest := sha256.Sum256([]byte("hello"))
+	sig, err := cert.Signer().Sign(rand.Reader, digest[:], crypto.SHA256)
+	require.NoError(t, err)
+	assert.True(t, ecdsa.VerifyASN1(&ecKey.PublicKey, digest[:], sig))
+
+	_, err = cert.Signer().Sign(rand.Reader, digest[:], crypto.SHA384)
+	assert.Error(t, err)
+}
+
+func TestParseAlgorithm(t *testing.T) {
+	hash, pss, err := parseAlgorithm(kmspb.CryptoKeyVersion_RSA_SIGN_PKCS1_4096_SHA512)
+	require.NoError(t, err)
+	assert.Equal(t, crypto.SHA512, hash)
+	assert.False(t, pss)
+
+	_, _, err = parseAlgorithm(kmspb.CryptoKeyVersion_RSA_SIGN_RAW_PKCS1_2048)
+	assert.Error(t, err)
+}
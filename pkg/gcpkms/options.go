@@ -0,0 +1,25 @@
+package gcpkms
+
+// Options select the Cloud KMS key version and how to reach it
+type Options struct {
+	// Resource name of the key version, e.g.
+	// projects/p/locations/global/keyRings/r/cryptoKeys/k/cryptoKeyVersions/1
+	KeyName string
+
+	// Service account or workload identity federation credentials, as a file
+	// or JSON. Without them, Application Default Credentials are used.
+	CredentialsFile string
+	CredentialsJSON string
+
+	// Custom endpoint, e.g. an emulator or private service connect address
+	Endpoint string
+	// Connect without TLS or authentication, for emulators
+	Insecure bool
+
+	// The certificate chain is read from a PEM file or a Secret Manager
+	// secret version, e.g. projects/p/secrets/codesign-chain/versions/latest
+	CertificateFile   string
+	CertificateSecret string
+	// Custom Secret Manager endpoint
+	SecretEndpoint string
+}
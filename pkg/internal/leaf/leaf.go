@@ -0,0 +1,23 @@
+// Package leaf finds the certificate of a remote signing key in a chain that
+// was loaded separately from the key.
+package leaf
+
+import (
+	"crypto"
+	"crypto/x509"
+	"fmt"
+
+	"github.com/ossign/ossign/pkg/sigerr"
+)
+
+// Move the certificate holding pub to the front of certs. keyName describes
+// the key in the error returned when no certificate matches.
+func First(certs []*x509.Certificate, pub crypto.PublicKey, keyName string) ([]*x509.Certificate, error) {
+	for i, cert := range certs {
+		if eq, ok := cert.PublicKey.(interface{ Equal(crypto.PublicKey) bool }); ok && eq.Equal(pub) {
+			certs[0], certs[i] = certs[i], certs[0]
+			return certs, nil
+		}
+	}
+	return nil, sigerr.ConfigError{Err: fmt.Errorf("no certificate matches the %s", keyName)}
+}
@@ -0,0 +1,37 @@
+package leaf
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"errors"
+	"testing"
+
+	"github.com/ossign/ossign/pkg/sigerr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFirst(t *testing.T) {
+	var certs []*x509.Certificate
+	var keys []*ecdsa.PrivateKey
+	for i := 0; i < 3; i++ {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		keys = append(keys, key)
+		certs = append(certs, &x509.Certificate{PublicKey: &key.PublicKey})
+	}
+	leaf := certs[2]
+
+	sorted, err := First(certs, &keys[2].PublicKey, "test key")
+	require.NoError(t, err)
+	assert.Same(t, leaf, sorted[0])
+	assert.Len(t, sorted, 3)
+
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	_, err = First(certs, &other.PublicKey, "test key")
+	assert.True(t, errors.As(err, &sigerr.ConfigError{}))
+	assert.ErrorContains(t, err, "no certificate matches the test key")
+}
@@ -0,0 +1,205 @@
+package ossign
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ossign/ossign/pkg/authenticode"
+	"github.com/ossign/ossign/pkg/sigerr"
+)
+
+// Attribute names of catalog members understood by Windows
+const (
+	CatalogAttrFile   = "File"
+	CatalogAttrOSAttr = "OSAttr"
+)
+
+// Settings for creating a catalog
+type CatalogOptions struct {
+	// OSAttr of every member, e.g. "2:6.1,2:10.0" for Windows 7 and 10
+	OSAttr string
+}
+
+// Create and sign a catalog of the given files. Directories are added
+// recursively. SHA-1 produces a version 1 catalog, which older Windows
+// versions need, and other digests a version 2 catalog.
+func (s *Signer) CreateCatalog(ctx context.Context, paths []string, copts CatalogOptions) ([]byte, error) {
+	sopts := s.mergeOptions(CatalogSignature, nil)
+	hash, err := sopts.HashFunc()
+	if err != nil {
+		return nil, err
+	}
+	if err := checkKeyHash(s.Cert, hash); err != nil {
+		return nil, err
+	}
+	if s.Config.SignatureType != CatalogSignature {
+		if err := s.Config.CertificatePolicy.checkUsage(s.Cert.Leaf, CatalogSignature); err != nil {
+			return nil, err
+		}
+	}
+
+	files, err := catalogFiles(paths)
+	if err != nil {
+		return nil, err
+	}
+	cat := authenticode.NewCatalog(hash)
+	seen := make(map[string]bool)
+	for _, file := range files {
+		imprint, pe, err := catalogDigest(file, hash)
+		if err != nil {
+			return nil, err
+		}
+		// Windows looks members up by digest, so identical files share one
+		if seen[string(imprint)] {
+			continue
+		}
+		seen[string(imprint)] = true
+
+		indirect, err := authenticode.MakeCatalogIndirect(imprint, hash, pe)
+		if err != nil {
+			return nil, sigerr.ConfigError{Err: err}
+		}
+		attrs := []authenticode.CatalogAttribute{{Name: CatalogAttrFile, Value: filepath.Base(file)}}
+		if copts.OSAttr != "" {
+			attrs = append(attrs, authenticode.CatalogAttribute{Name: CatalogAttrOSAttr, Value: copts.OSAttr})
+		}
+		if err := cat.AddMember(indirect, attrs); err != nil {
+			return nil, fmt.Errorf("adding %s to catalog: %w", file, err)
+		}
+	}
+
+	opus := sopts.OpusParams(nil)
+	defer s.lock()()
+	sig, err := cat.Sign(ctx, s.Cert, &authenticode.OpusParams{Description: opus.Description, URL: opus.URL})
+	if err != nil {
+		return nil, fmt.Errorf("Error signing catalog: %w", err)
+	}
+	return sig.Raw, nil
+}
+
+// The result of checking one file against a catalog
+type CatalogCheck struct {
+	// Path relative to the checked directory, or the File attribute of a
+	// member without a matching file
+	Name string
+	Err  error
+}
+
+// Verify the signature of a catalog and check every file below dir against
+// it. Catalog files themselves are skipped. Members naming a file that was
+// not found are reported as missing.
+func VerifyCatalog(catalog []byte, dir string) (*VerifiedSignature, []CatalogCheck, error) {
+	sig, err := authenticode.VerifyCatalog(catalog)
+	if err != nil {
+		return nil, nil, sigerr.VerifyError{Err: err}
+	}
+
+	hashes := make(map[crypto.Hash]bool)
+	members := make(map[string]int)
+	for i, member := range sig.Members {
+		hashes[member.HashFunc] = true
+		members[memberKey(member.HashFunc, member.Digest)] = i
+	}
+
+	files, err := catalogFiles([]string{dir})
+	if err != nil {
+		return nil, nil, err
+	}
+	var checks []CatalogCheck
+	matched := make(map[int]bool)
+	for _, file := range files {
+		if strings.EqualFold(filepath.Ext(file), ".cat") {
+			continue
+		}
+		name, _ := filepath.Rel(dir, file)
+		check := CatalogCheck{Name: name, Err: sigerr.VerifyError{Err: errors.New("not listed in the catalog")}}
+		for hash := range hashes {
+			imprint, _, err := catalogDigest(file, hash)
+			if err != nil {
+				check.Err = err
+				break
+			}
+			if i, ok := members[memberKey(hash, imprint)]; ok {
+				matched[i] = true
+				check.Err = nil
+				break
+			}
+		}
+		checks = append(checks, check)
+	}
+
+	for i, member := range sig.Members {
+		if name := member.Attributes[CatalogAttrFile]; !matched[i] && name != "" {
+			checks = append(checks, CatalogCheck{Name: name, Err: sigerr.VerifyError{Err: errors.New("listed in the catalog but missing")}})
+		}
+	}
+
+	return &VerifiedSignature{TimestampedSignature: sig.TimestampedSignature, HashFunc: sig.HashFunc}, checks, nil
+}
+
+func memberKey(hash crypto.Hash, digest []byte) string {
+	return hash.String() + ":" + hex.EncodeToString(digest)
+}
+
+// Expand directories into the regular files below them, in a stable order
+func catalogFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, p)
+			continue
+		}
+		var found []string
+		err = filepath.WalkDir(p, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.Type().IsRegular() {
+				found = append(found, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(found)
+		files = append(files, found...)
+	}
+	return files, nil
+}
+
+// Digest a file for a catalog: PE images by their Authenticode digest, so that
+// signing them later does not invalidate the catalog, and other files whole
+func catalogDigest(file string, hash crypto.Hash) (imprint []byte, pe bool, err error) {
+	blob, err := os.ReadFile(file)
+	if err != nil {
+		return nil, false, err
+	}
+	header := blob
+	if len(header) > 512 {
+		header = header[:512]
+	}
+	if isPE(bytes.NewReader(blob), header) {
+		digest, err := authenticode.DigestPE(bytes.NewReader(blob), hash, false)
+		if err != nil {
+			return nil, false, sigerr.FormatError{Err: fmt.Errorf("digesting %s: %w", file, err)}
+		}
+		return digest.Imprint, true, nil
+	}
+	d := hash.New()
+	d.Write(blob)
+	return d.Sum(nil), false, nil
+}
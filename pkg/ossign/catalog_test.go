@@ -0,0 +1,47 @@
+package ossign
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ossign/ossign/pkg/authenticode"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCatalog(t *testing.T) {
+	s := testSigner(t)
+
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "amd64"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "driver.inf"), []byte("[Version]\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "amd64", "driver.bin"), []byte("firmware"), 0644))
+
+	catalog, err := s.CreateCatalog(context.Background(), []string{dir}, CatalogOptions{OSAttr: "2:10.0"})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "driver.cat"), catalog, 0644))
+
+	sig, err := authenticode.VerifyCatalog(catalog)
+	require.NoError(t, err)
+	require.Len(t, sig.Members, 2)
+	assert.Equal(t, "driver.bin", sig.Members[0].Attributes[CatalogAttrFile])
+	assert.Equal(t, "2:10.0", sig.Members[0].Attributes[CatalogAttrOSAttr])
+
+	_, checks, err := VerifyCatalog(catalog, dir)
+	require.NoError(t, err)
+	require.Len(t, checks, 2)
+	for _, check := range checks {
+		assert.NoError(t, check.Err, check.Name)
+	}
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "driver.inf"), []byte("[Version]\nchanged\n"), 0644))
+	require.NoError(t, os.Remove(filepath.Join(dir, "amd64", "driver.bin")))
+	_, checks, err = VerifyCatalog(catalog, dir)
+	require.NoError(t, err)
+	require.Len(t, checks, 3)
+	assert.ErrorContains(t, checks[0].Err, "not listed")
+	assert.ErrorContains(t, checks[1].Err, "missing")
+	assert.ErrorContains(t, checks[2].Err, "missing")
+}
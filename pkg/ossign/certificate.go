@@ -0,0 +1,137 @@
+package ossign
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ossign/ossign/pkg/sigerr"
+	"github.com/sassoftware/relic/v8/lib/certloader"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// Whether any kind of private key is configured
+func (c CertConfig) hasKey() bool {
+	return c.PrivateKey != "" || c.PrivateKeyFile != "" || c.Pkcs12 != "" || c.Pkcs12File != ""
+}
+
+// Load the certificate chain and private key, either from a PKCS#12 bundle
+// or from PEM given inline or as file paths
+func (c CertConfig) Load() (*certloader.Certificate, error) {
+	if c.Pkcs12 != "" || c.Pkcs12File != "" {
+		return c.loadPkcs12()
+	}
+
+	certBlob, err := readInlineOrFile(c.Certificate, c.CertificateFile, "certificate")
+	if err != nil {
+		return nil, err
+	}
+	certs, err := certloader.ParseX509Certificates(certBlob)
+	if err != nil {
+		return nil, sigerr.ConfigError{Err: fmt.Errorf("parsing certificate: %w", err)}
+	}
+	if len(certs) == 0 {
+		return nil, sigerr.ConfigError{Err: fmt.Errorf("no certificates found")}
+	}
+
+	keyBlob, err := readInlineOrFile(c.PrivateKey, c.PrivateKeyFile, "private key")
+	if err != nil {
+		return nil, err
+	}
+	key, err := certloader.ParseAnyPrivateKey(keyBlob, c.passphraseGetter())
+	if err != nil {
+		return nil, sigerr.ConfigError{Err: fmt.Errorf("parsing private key: %w", err)}
+	}
+
+	cert := &certloader.Certificate{
+		Leaf:         certs[0],
+		Certificates: certs,
+		PrivateKey:   key,
+	}
+	return cert, checkKeyPair(cert)
+}
+
+func (c CertConfig) loadPkcs12() (*certloader.Certificate, error) {
+	var blob []byte
+	if c.Pkcs12File != "" {
+		var err error
+		blob, err = os.ReadFile(c.Pkcs12File)
+		if err != nil {
+			return nil, sigerr.ConfigError{Err: fmt.Errorf("reading PKCS#12 file: %w", err)}
+		}
+	} else {
+		var err error
+		blob, err = base64.StdEncoding.DecodeString(strings.Join(strings.Fields(c.Pkcs12), ""))
+		if err != nil {
+			return nil, sigerr.ConfigError{Err: fmt.Errorf("decoding PKCS#12 blob: %w", err)}
+		}
+	}
+
+	// Bundles without a passphrase are common, so only ask for one when the
+	// empty passphrase does not work
+	getter := c.passphraseGetter()
+	var password string
+	if c.hasPassphrase() {
+		var err error
+		if password, err = getter.GetPasswd("Passphrase for PKCS#12: "); err != nil {
+			return nil, err
+		}
+	}
+	key, leaf, chain, err := pkcs12.DecodeChain(blob, password)
+	for errors.Is(err, pkcs12.ErrIncorrectPassword) {
+		if password, err = getter.GetPasswd("Passphrase for PKCS#12: "); err != nil {
+			return nil, err
+		}
+		key, leaf, chain, err = pkcs12.DecodeChain(blob, password)
+	}
+	if err != nil {
+		return nil, sigerr.ConfigError{Err: fmt.Errorf("parsing PKCS#12: %w", err)}
+	}
+
+	cert := &certloader.Certificate{
+		Leaf:         leaf,
+		Certificates: append([]*x509.Certificate{leaf}, chain...),
+		PrivateKey:   key,
+	}
+	return cert, checkKeyPair(cert)
+}
+
+// Return the inline value, or the contents of the file if no inline value
+// was given
+func readInlineOrFile(inline, path, what string) ([]byte, error) {
+	if inline != "" {
+		return []byte(inline), nil
+	}
+	if path == "" {
+		return nil, sigerr.ConfigError{Err: fmt.Errorf("no %s configured", what)}
+	}
+	blob, err := os.ReadFile(path)
+	if err != nil {
+		return nil, sigerr.ConfigError{Err: fmt.Errorf("reading %s file: %w", what, err)}
+	}
+	return blob, nil
+}
+
+// Make sure the key can be used for signing and belongs to the leaf certificate
+func checkKeyPair(cert *certloader.Certificate) error {
+	var pub crypto.PublicKey
+	switch key := cert.PrivateKey.(type) {
+	case *rsa.PrivateKey:
+		pub = key.Public()
+	case *ecdsa.PrivateKey:
+		pub = key.Public()
+	default:
+		return sigerr.ConfigError{Err: fmt.Errorf("unsupported private key type %T, only RSA and ECDSA keys can be used", cert.PrivateKey)}
+	}
+
+	if !pub.(interface{ Equal(crypto.PublicKey) bool }).Equal(cert.Leaf.PublicKey) {
+		return sigerr.ConfigError{Err: errors.New("private key does not match the certificate")}
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package ossign
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ossign/ossign/pkg/sigerr"
+	"github.com/sassoftware/relic/v8/lib/certloader"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+func testCertificate(t *testing.T) (*ecdsa.PrivateKey, *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "test signer"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return key, cert
+}
+
+// A Signer with the certificate of testCertificate and the default
+// configuration
+func testSigner(t *testing.T) *Signer {
+	key, leaf := testCertificate(t)
+	return &Signer{
+		Config: &SigningConfig{},
+		Cert:   &certloader.Certificate{Leaf: leaf, Certificates: []*x509.Certificate{leaf}, PrivateKey: key},
+	}
+}
+
+func TestCertConfigPkcs12(t *testing.T) {
+	key, leaf := testCertificate(t)
+	pfx, err := pkcs12.Modern.Encode(key, leaf, nil, "secret")
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "signer.pfx")
+	require.NoError(t, os.WriteFile(path, pfx, 0600))
+
+	for _, conf := range []CertConfig{
+		{Pkcs12File: path, Passphrase: "secret"},
+		{Pkcs12: base64.StdEncoding.EncodeToString(pfx), Passphrase: "secret"},
+	} {
+		cert, err := conf.Load()
+		require.NoError(t, err)
+		assert.True(t, cert.Leaf.Equal(leaf))
+		assert.True(t, key.Equal(cert.PrivateKey))
+	}
+
+	_, err = CertConfig{Pkcs12File: path, Passphrase: "wrong"}.Load()
+	assert.True(t, errors.As(err, &sigerr.ConfigError{}))
+}
+
+func TestCertConfigFiles(t *testing.T) {
+	key, leaf := testCertificate(t)
+	keyDer, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	certPath := filepath.Join(dir, "cert.pem")
+	keyPath := filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leaf.Raw}), 0600))
+	require.NoError(t, os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDer}), 0600))
+
+	cert, err := CertConfig{CertificateFile: certPath, PrivateKeyFile: keyPath}.Load()
+	require.NoError(t, err)
+	assert.True(t, cert.Leaf.Equal(leaf))
+
+	// A key that does not belong to the certificate is rejected
+	otherKey, _ := testCertificate(t)
+	otherDer, err := x509.MarshalPKCS8PrivateKey(otherKey)
+	require.NoError(t, err)
+	_, err = CertConfig{
+		CertificateFile: certPath,
+		PrivateKey:      string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: otherDer})),
+	}.Load()
+	assert.True(t, errors.As(err, &sigerr.ConfigError{}))
+}
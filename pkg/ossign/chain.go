@@ -0,0 +1,153 @@
+package ossign
+
+import (
+	"bytes"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/ossign/ossign/pkg/sigerr"
+	"github.com/sassoftware/relic/v8/lib/certloader"
+)
+
+// Longest chain followed from the leaf, to stop at certificate loops
+const maxChainLength = 10
+
+// Complete the chain of the signing certificate with the configured extra
+// certificates, and order it from the leaf up without duplicates or the root
+func (c *SigningConfig) completeChain(cert *certloader.Certificate) error {
+	candidates := cert.Certificates
+	if c.ExtraCertificates != "" {
+		blob, err := os.ReadFile(c.ExtraCertificates)
+		if err != nil {
+			return sigerr.ConfigError{Err: fmt.Errorf("reading extra certificates: %w", err)}
+		}
+		extra, err := certloader.ParseX509Certificates(blob)
+		if err != nil {
+			return sigerr.ConfigError{Err: fmt.Errorf("parsing extra certificates: %w", err)}
+		}
+		candidates = append(append([]*x509.Certificate{}, candidates...), extra...)
+	}
+
+	chain, root, err := buildChain(cert.Leaf, candidates)
+	if err != nil {
+		return sigerr.ConfigError{Err: err}
+	}
+	cert.Certificates = chain
+	if c.SkipChainCheck {
+		return nil
+	}
+
+	roots, err := c.trustedRoots()
+	if err != nil {
+		return err
+	}
+	if err := checkChain(chain, root, roots); err != nil {
+		if c.RequireTrustedRoot {
+			return sigerr.ConfigError{Err: err}
+		}
+		// code signing roots are often missing from the system store, so
+		// the signature may still be valid where it is verified
+		log.Printf("Warning: %s", err)
+	}
+	return nil
+}
+
+// The system roots together with the configured trusted roots
+func (c *SigningConfig) trustedRoots() (*x509.CertPool, error) {
+	// missing system roots only mean that every root has to be configured
+	roots, _ := x509.SystemCertPool()
+	if roots == nil {
+		roots = x509.NewCertPool()
+	}
+	if c.TrustedRoots == "" {
+		return roots, nil
+	}
+	blob, err := os.ReadFile(c.TrustedRoots)
+	if err != nil {
+		return nil, sigerr.ConfigError{Err: fmt.Errorf("reading trusted roots: %w", err)}
+	}
+	certs, err := certloader.ParseX509Certificates(blob)
+	if err != nil {
+		return nil, sigerr.ConfigError{Err: fmt.Errorf("parsing trusted roots: %w", err)}
+	}
+	for _, cert := range certs {
+		roots.AddCert(cert)
+	}
+	return roots, nil
+}
+
+// Follow the issuers of leaf through the candidates, and return the chain
+// together with the self-signed root it ends in, if that was found
+func buildChain(leaf *x509.Certificate, candidates []*x509.Certificate) ([]*x509.Certificate, *x509.Certificate, error) {
+	chain := []*x509.Certificate{leaf}
+	cur := leaf
+	for !selfSigned(cur) {
+		if len(chain) > maxChainLength {
+			return nil, nil, fmt.Errorf("certificate chain of %s is longer than %d certificates", leaf.Subject, maxChainLength)
+		}
+		issuer := findIssuer(cur, candidates, chain)
+		if issuer == nil {
+			break
+		}
+		if selfSigned(issuer) {
+			return chain, issuer, nil
+		}
+		chain = append(chain, issuer)
+		cur = issuer
+	}
+	return chain, nil, nil
+}
+
+// Check that the last certificate of the chain, or the root it ends in, is
+// trusted by roots
+func checkChain(chain []*x509.Certificate, root *x509.Certificate, roots *x509.CertPool) error {
+	cur := chain[len(chain)-1]
+	_, err := cur.Verify(x509.VerifyOptions{
+		Roots:       roots,
+		CurrentTime: cur.NotBefore,
+		KeyUsages:   []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err == nil {
+		return nil
+	}
+	switch {
+	case selfSigned(cur):
+		return fmt.Errorf("certificate chain is incomplete: self-signed %s is not a trusted root, add it to trustedRoots", cur.Subject)
+	case root != nil:
+		return fmt.Errorf("certificate chain is incomplete: root %s of %s is not trusted, add it to trustedRoots", root.Subject, cur.Subject)
+	}
+	return fmt.Errorf("certificate chain is incomplete: issuer %s of %s not found, add it to extraCertificates", cur.Issuer, cur.Subject)
+}
+
+// Find the certificate that issued cert, skipping those already in the chain
+func findIssuer(cert *x509.Certificate, candidates, chain []*x509.Certificate) *x509.Certificate {
+	for _, cand := range candidates {
+		if !bytes.Equal(cand.RawSubject, cert.RawIssuer) || contains(chain, cand) {
+			continue
+		}
+		if cert.CheckSignatureFrom(cand) == nil {
+			return cand
+		}
+	}
+	return nil
+}
+
+func selfSigned(cert *x509.Certificate) bool {
+	if !bytes.Equal(cert.RawSubject, cert.RawIssuer) {
+		return false
+	}
+	err := cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature)
+	return err == nil || errors.Is(err, x509.InsecureAlgorithmError(cert.SignatureAlgorithm))
+}
+
+func contains(certs []*x509.Certificate, cert *x509.Certificate) bool {
+	for _, c := range certs {
+		if bytes.Equal(c.Raw, cert.Raw) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,179 @@
+package ossign
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ossign/ossign/pkg/sigerr"
+	"github.com/sassoftware/relic/v8/lib/certloader"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testCA struct {
+	key  *ecdsa.PrivateKey
+	cert *x509.Certificate
+}
+
+// Issue a certificate from parent, or a self-signed one without a parent
+func issue(t *testing.T, name string, isCA bool, parent *testCA) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+	}
+	issuer, signer := template, key
+	if parent != nil {
+		issuer, signer = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, issuer, key.Public(), signer)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &testCA{key: key, cert: cert}
+}
+
+func TestBuildChain(t *testing.T) {
+	root := issue(t, "root", true, nil)
+	inter := issue(t, "intermediate", true, root)
+	leaf := issue(t, "leaf", false, inter)
+	roots := x509.NewCertPool()
+	roots.AddCert(root.cert)
+
+	// out of order, duplicated and with the root
+	chain, end, err := buildChain(leaf.cert, []*x509.Certificate{root.cert, inter.cert, leaf.cert, inter.cert})
+	require.NoError(t, err)
+	assert.Equal(t, []*x509.Certificate{leaf.cert, inter.cert}, chain)
+	assert.Equal(t, root.cert, end)
+	assert.NoError(t, checkChain(chain, end, roots))
+	assert.ErrorContains(t, checkChain(chain, end, x509.NewCertPool()), "root CN=root of CN=intermediate is not trusted")
+
+	// the root only has to be known
+	chain, end, err = buildChain(leaf.cert, []*x509.Certificate{inter.cert})
+	require.NoError(t, err)
+	assert.Equal(t, []*x509.Certificate{leaf.cert, inter.cert}, chain)
+	assert.Nil(t, end)
+	assert.NoError(t, checkChain(chain, end, roots))
+	assert.ErrorContains(t, checkChain(chain, end, x509.NewCertPool()), "issuer CN=root of CN=intermediate not found")
+
+	// the chain is completed as far as possible
+	chain, end, err = buildChain(leaf.cert, []*x509.Certificate{root.cert})
+	require.NoError(t, err)
+	assert.Equal(t, []*x509.Certificate{leaf.cert}, chain)
+	assert.ErrorContains(t, checkChain(chain, end, roots), "issuer CN=intermediate of CN=leaf not found")
+
+	self := issue(t, "self-signed", false, nil)
+	chain, end, err = buildChain(self.cert, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []*x509.Certificate{self.cert}, chain)
+	assert.ErrorContains(t, checkChain(chain, end, x509.NewCertPool()), "self-signed CN=self-signed is not a trusted root")
+	selfRoots := x509.NewCertPool()
+	selfRoots.AddCert(self.cert)
+	assert.NoError(t, checkChain(chain, end, selfRoots))
+}
+
+// Write certs to a PEM bundle in a temporary directory
+func writeBundle(t *testing.T, certs ...*x509.Certificate) string {
+	path := filepath.Join(t.TempDir(), "bundle.pem")
+	var bundle []byte
+	for _, cert := range certs {
+		bundle = append(bundle, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})...)
+	}
+	require.NoError(t, os.WriteFile(path, bundle, 0600))
+	return path
+}
+
+func TestCompleteChainExtraCertificates(t *testing.T) {
+	root := issue(t, "root", true, nil)
+	inter := issue(t, "intermediate", true, root)
+	leaf := issue(t, "leaf", false, inter)
+	extra := writeBundle(t, root.cert, inter.cert)
+
+	// the test root is not a system root, which is only a warning
+	for _, conf := range []*SigningConfig{
+		{ExtraCertificates: extra},
+		{ExtraCertificates: extra, SkipChainCheck: true, RequireTrustedRoot: true},
+		{ExtraCertificates: extra, TrustedRoots: writeBundle(t, root.cert), RequireTrustedRoot: true},
+	} {
+		cert := &certloader.Certificate{Leaf: leaf.cert, Certificates: []*x509.Certificate{leaf.cert}}
+		require.NoError(t, conf.completeChain(cert))
+		assert.Equal(t, []*x509.Certificate{leaf.cert, inter.cert}, cert.Certificates)
+	}
+
+	cert := &certloader.Certificate{Leaf: leaf.cert, Certificates: []*x509.Certificate{leaf.cert}}
+	err := (&SigningConfig{ExtraCertificates: extra, RequireTrustedRoot: true}).completeChain(cert)
+	assert.True(t, errors.As(err, &sigerr.ConfigError{}))
+	cert = &certloader.Certificate{Leaf: leaf.cert, Certificates: []*x509.Certificate{leaf.cert}}
+	err = (&SigningConfig{TrustedRoots: writeBundle(t, root.cert), RequireTrustedRoot: true}).completeChain(cert)
+	assert.True(t, errors.As(err, &sigerr.ConfigError{}), "the intermediate is missing")
+	err = (&SigningConfig{TrustedRoots: filepath.Join(t.TempDir(), "missing.pem")}).completeChain(cert)
+	assert.True(t, errors.As(err, &sigerr.ConfigError{}))
+}
+
+func TestNewSignerUntrustedRoot(t *testing.T) {
+	root := issue(t, "private root", true, nil)
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "test signer"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, root.cert, key.Public(), root.key)
+	require.NoError(t, err)
+	keyDer, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	// the default configuration signs with a chain ending in a private root
+	s, err := NewSigner(context.Background(), &SigningConfig{
+		TokenType:         TokenTypeCertificate,
+		NoTimestamp:       true,
+		ExtraCertificates: writeBundle(t, root.cert),
+		CertConfig: CertConfig{
+			Certificate: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+			PrivateKey:  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDer})),
+		},
+	})
+	require.NoError(t, err)
+	defer s.Close()
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create("META-INF/MANIFEST.MF")
+	require.NoError(t, err)
+	_, err = w.Write([]byte("Manifest-Version: 1.0\r\n\r\n"))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	jar := buf.Bytes()
+
+	r, err := s.Sign(context.Background(), bytes.NewReader(jar), int64(len(jar)), JarSignature, &SignOptions{Filename: "app.jar"})
+	require.NoError(t, err)
+	signed, err := io.ReadAll(r)
+	require.NoError(t, err)
+	sigs, err := Verify(bytes.NewReader(signed), int64(len(signed)), AutoSignature, "app.jar")
+	require.NoError(t, err)
+	require.Len(t, sigs, 1)
+	assert.Equal(t, "test signer", sigs[0].Certificate.Subject.CommonName)
+}
@@ -0,0 +1,502 @@
+package ossign
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ossign/ossign/pkg/awskms"
+	"github.com/ossign/ossign/pkg/azure"
+	"github.com/ossign/ossign/pkg/gcpkms"
+	"github.com/ossign/ossign/pkg/pkcs11"
+	"github.com/ossign/ossign/pkg/sigerr"
+	"github.com/ossign/ossign/pkg/timestamp"
+	"github.com/ossign/ossign/pkg/vault"
+	"github.com/sassoftware/relic/v8/lib/certloader"
+	"github.com/sassoftware/relic/v8/lib/pkcs9"
+)
+
+const (
+	DefaultTimestampUrl   = "http://timestamp.globalsign.com/tsa/advanced"
+	DefaultMsTimestampUrl = "http://timestamp.microsoft.com/tsa"
+)
+
+type TokenType string
+type SignatureType string
+
+const (
+	TokenTypeAzure        TokenType = "azure"
+	TokenTypeAzureTrusted TokenType = "azureTrusted"
+	TokenTypeCertificate  TokenType = "certificate"
+	TokenTypePkcs11       TokenType = "pkcs11"
+	TokenTypeVaultTransit TokenType = "vaultTransit"
+	TokenTypeAwsKms       TokenType = "awsKms"
+	TokenTypeGcpKms       TokenType = "gcpKms"
+
+	AutoSignature         SignatureType = "auto"
+	PowershellSignature   SignatureType = "powershell"
+	PecoffSignature       SignatureType = "pecoff"
+	AuthenticodeSignature SignatureType = "authenticode"
+	MsiSignature          SignatureType = "msi"
+	AppxSignature         SignatureType = "appx"
+	AppmanifestSignature  SignatureType = "appmanifest"
+	DmgSignature          SignatureType = "dmg"
+	MachosSignature       SignatureType = "machos"
+	CabSignature          SignatureType = "cab"
+	CatalogSignature      SignatureType = "catalog"
+	JarSignature          SignatureType = "jar"
+	ApkSignature          SignatureType = "apk"
+)
+
+// func (st SignatureType) GetTransformer(file vfs.File) (signers.Transformer, error) {
+// 	return transformers.NewNoFileTransformer(file), nil
+
+// 	// switch st {
+// 	// case AutoSignature:
+// 	// case PowershellSignature:
+// 	// 	return transformers.NewNoFileTransformer(file).GetReader()
+// 	// case PecoffSignature:
+// 	// 	return transformers.NewPEFileTransformer(file).GetReader()
+// 	// case AuthenticodeSignature:
+// 	// 	return transformers.NewAuthenticodeFileTransformer(file).GetReader()
+// 	// default:
+// 	// 	return nil, fmt.Errorf("unknown signature type: %s", st)
+// 	// }
+// }
+
+type SigningConfig struct {
+	TokenType     TokenType     `json:"tokenType" yaml:"tokenType" mapstructure:"tokenType"`
+	SignatureType SignatureType `json:"signatureType" yaml:"signatureType" mapstructure:"signatureType"`
+
+	AzureConfig        AzureConfig        `json:"azure,omitempty" yaml:"azure,omitempty" mapstructure:"azure"`
+	AzureTrustedConfig AzureTrustedConfig `json:"azureTrusted,omitempty" yaml:"azureTrusted,omitempty" mapstructure:"azureTrusted"`
+
+	CertConfig CertConfig `json:"certificate,omitempty" yaml:"certificate,omitempty" mapstructure:"certificate"`
+
+	Pkcs11Config Pkcs11Config `json:"pkcs11,omitempty" yaml:"pkcs11,omitempty" mapstructure:"pkcs11"`
+
+	VaultTransitConfig VaultTransitConfig `json:"vaultTransit,omitempty" yaml:"vaultTransit,omitempty" mapstructure:"vaultTransit"`
+
+	AwsKmsConfig AwsKmsConfig `json:"awsKms,omitempty" yaml:"awsKms,omitempty" mapstructure:"awsKms"`
+	GcpKmsConfig GcpKmsConfig `json:"gcpKms,omitempty" yaml:"gcpKms,omitempty" mapstructure:"gcpKms"`
+
+	TimestampUrl   string `json:"timestampUrl,omitempty" yaml:"timestampUrl,omitempty" mapstructure:"timestampUrl"`
+	MsTimestampUrl string `json:"msTimestampUrl,omitempty" yaml:"msTimestampUrl,omitempty" mapstructure:"msTimestampUrl"`
+
+	// Additional timestamp servers, tried in order after the ones above
+	TimestampUrls   []string `json:"timestampUrls,omitempty" yaml:"timestampUrls,omitempty" mapstructure:"timestampUrls"`
+	MsTimestampUrls []string `json:"msTimestampUrls,omitempty" yaml:"msTimestampUrls,omitempty" mapstructure:"msTimestampUrls"`
+	// Seconds allowed for a single timestamp request
+	TimestampTimeout int `json:"timestampTimeout,omitempty" yaml:"timestampTimeout,omitempty" mapstructure:"timestampTimeout"`
+	// How often to retry all timestamp servers, waiting longer every time
+	TimestampRetries int  `json:"timestampRetries,omitempty" yaml:"timestampRetries,omitempty" mapstructure:"timestampRetries"`
+	NoTimestamp      bool `json:"noTimestamp,omitempty" yaml:"noTimestamp,omitempty" mapstructure:"noTimestamp"`
+
+	InputFile  string `json:"inputFile" yaml:"inputFile" mapstructure:"inputFile"`
+	OutputFile string `json:"outputFile" yaml:"outputFile" mapstructure:"outputFile"`
+	InPlace    bool   `json:"inPlace,omitempty" yaml:"inPlace,omitempty" mapstructure:"inPlace"`
+
+	// Digest algorithm for all formats, and overrides for single formats
+	HashAlgorithm string                         `json:"hashAlgorithm,omitempty" yaml:"hashAlgorithm,omitempty" mapstructure:"hashAlgorithm"`
+	Formats       map[SignatureType]FormatConfig `json:"formats,omitempty" yaml:"formats,omitempty" mapstructure:"formats"`
+
+	// Nest the signature inside an existing one instead of replacing it
+	AppendSignature bool `json:"appendSignature,omitempty" yaml:"appendSignature,omitempty" mapstructure:"appendSignature"`
+
+	// Publisher information shown in the Windows "more info" dialog
+	Description                string `json:"description,omitempty" yaml:"description,omitempty" mapstructure:"description"`
+	URL                        string `json:"url,omitempty" yaml:"url,omitempty" mapstructure:"url"`
+	DescriptionFromVersionInfo bool   `json:"descriptionFromVersionInfo,omitempty" yaml:"descriptionFromVersionInfo,omitempty" mapstructure:"descriptionFromVersionInfo"`
+
+	// PEM file with intermediate certificates to add to the chain of the
+	// signing certificate, for tokens that only return the leaf
+	ExtraCertificates string `json:"extraCertificates,omitempty" yaml:"extraCertificates,omitempty" mapstructure:"extraCertificates"`
+	// PEM file with roots to trust in addition to the system roots
+	TrustedRoots string `json:"trustedRoots,omitempty" yaml:"trustedRoots,omitempty" mapstructure:"trustedRoots"`
+	// Fail instead of warning when the chain does not lead to a trusted root
+	RequireTrustedRoot bool `json:"requireTrustedRoot,omitempty" yaml:"requireTrustedRoot,omitempty" mapstructure:"requireTrustedRoot"`
+	// Do not check whether the chain leads to a trusted root at all
+	SkipChainCheck bool `json:"skipChainCheck,omitempty" yaml:"skipChainCheck,omitempty" mapstructure:"skipChainCheck"`
+
+	CertificatePolicy CertificatePolicy `json:"certificatePolicy,omitempty" yaml:"certificatePolicy,omitempty" mapstructure:"certificatePolicy"`
+
+	Params map[string]string `json:"params,omitempty" yaml:"params,omitempty" mapstructure:"params"`
+}
+
+func (c *SigningConfig) GetParamDefault(key, def string) string {
+	if val, ok := c.Params[key]; ok {
+		return val
+	}
+	return def
+}
+
+func UnmarshalConfig(path string) (*SigningConfig, error) {
+	fileContent, err := os.ReadFile(path)
+	if err != nil {
+		return nil, sigerr.ConfigError{Err: fmt.Errorf("error reading config file: %w", err)}
+	}
+
+	var config SigningConfig
+	err = json.Unmarshal(fileContent, &config)
+	if err != nil {
+		return nil, sigerr.ConfigError{Err: fmt.Errorf("error parsing config file: %w", err)}
+	}
+
+	return &config, config.Validate()
+}
+
+func (c *SigningConfig) Validate() error {
+	if c.InputFile == "" || c.TokenType == "" {
+		return sigerr.ConfigError{Err: fmt.Errorf("missing required configuration")}
+	}
+
+	switch c.TokenType {
+	case TokenTypeCertificate:
+		if !c.CertConfig.hasKey() {
+			return sigerr.ConfigError{Err: fmt.Errorf("missing required configuration")}
+		}
+	}
+
+	if c.SignatureType == "" {
+		c.SignatureType = AutoSignature
+	}
+
+	if c.TimestampUrl == "" && len(c.TimestampUrls) == 0 {
+		c.TimestampUrl = DefaultTimestampUrl
+	}
+
+	if c.MsTimestampUrl == "" && len(c.MsTimestampUrls) == 0 {
+		c.MsTimestampUrl = DefaultMsTimestampUrl
+	}
+
+	return nil
+}
+
+// Build the timestamper from the configured servers, or nil when
+// timestamping is disabled
+func (c *SigningConfig) GetTimestamper() (pkcs9.Timestamper, error) {
+	if c.NoTimestamp {
+		return nil, nil
+	}
+
+	conf := timestamp.Config{
+		URLs:    withFirst(c.TimestampUrl, c.TimestampUrls),
+		MsURLs:  withFirst(c.MsTimestampUrl, c.MsTimestampUrls),
+		Timeout: time.Duration(c.TimestampTimeout) * time.Second,
+		Retries: c.TimestampRetries,
+	}
+	if len(conf.URLs) == 0 {
+		conf.URLs = []string{DefaultTimestampUrl}
+	}
+	if len(conf.MsURLs) == 0 {
+		conf.MsURLs = []string{DefaultMsTimestampUrl}
+	}
+
+	t, err := timestamp.New(conf)
+	if err != nil {
+		return nil, sigerr.ConfigError{Err: fmt.Errorf("creating timestamper: %w", err)}
+	}
+	return t, nil
+}
+
+func withFirst(first string, rest []string) []string {
+	if first == "" {
+		return rest
+	}
+	return append([]string{first}, rest...)
+}
+
+// Load the signing key and certificate of the configured token, with its
+// chain completed and checked against the certificate policy
+func (c *SigningConfig) GetSigner(timestamper pkcs9.Timestamper, ctx context.Context) (*certloader.Certificate, error) {
+	signerCert, err := c.loadSigner(timestamper, ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.completeChain(signerCert); err != nil {
+		closeKey(signerCert)
+		return nil, err
+	}
+	if err := c.CertificatePolicy.check(signerCert.Leaf, c.SignatureType, time.Now()); err != nil {
+		closeKey(signerCert)
+		return nil, err
+	}
+	return signerCert, nil
+}
+
+func (c *SigningConfig) loadSigner(timestamper pkcs9.Timestamper, ctx context.Context) (signerCert *certloader.Certificate, err error) {
+	timestamper = sigerr.WrapTimestamper(timestamper)
+
+	switch c.TokenType {
+	case TokenTypeAzure:
+		azcr, err := azure.NewCredential(c.AzureConfig.credentialOptions())
+		if err != nil {
+			return nil, err
+		}
+
+		azconfig, err := azure.NewAzureKey(c.AzureConfig.VaultUrl, azcr, c.AzureConfig.CertificateName, c.AzureConfig.CertificateVersion, ctx)
+		if err != nil {
+			return nil, fmt.Errorf("Error creating Azure key: %w", err)
+		}
+
+		signerCert = &certloader.Certificate{
+			Leaf:         azconfig.Fcert[0],
+			Certificates: azconfig.Fcert,
+			PrivateKey:   azconfig,
+			Timestamper:  timestamper,
+		}
+
+		return signerCert, nil
+	case TokenTypeAzureTrusted:
+		azcr, err := azure.NewCredential(c.AzureTrustedConfig.credentialOptions())
+		if err != nil {
+			return nil, err
+		}
+
+		return azure.NewAzureTrustedKey(azure.TrustedOptions{
+			Region:   c.AzureTrustedConfig.Region,
+			Account:  c.AzureTrustedConfig.Account,
+			Profile:  c.AzureTrustedConfig.Profile,
+			CacheTTL: time.Duration(c.AzureTrustedConfig.CertificateCacheTtl) * time.Second,
+			NoCache:  c.AzureTrustedConfig.NoCertificateCache,
+		}, azcr, ctx, timestamper)
+	case TokenTypePkcs11:
+		pin, err := c.Pkcs11Config.GetPin()
+		if err != nil {
+			return nil, err
+		}
+		return pkcs11.NewPkcs11Key(pkcs11.Options{
+			Module:          c.Pkcs11Config.Module,
+			Slot:            c.Pkcs11Config.Slot,
+			TokenLabel:      c.Pkcs11Config.TokenLabel,
+			TokenSerial:     c.Pkcs11Config.TokenSerial,
+			KeyLabel:        c.Pkcs11Config.KeyLabel,
+			KeyId:           c.Pkcs11Config.KeyId,
+			Pin:             pin,
+			CertificateFile: c.Pkcs11Config.CertificateFile,
+		}, ctx, timestamper)
+	case TokenTypeVaultTransit:
+		return c.VaultTransitConfig.getSigner(timestamper, ctx)
+	case TokenTypeAwsKms:
+		return awskms.NewKmsKey(awskms.Options{
+			KeyId:           c.AwsKmsConfig.KeyId,
+			Region:          c.AwsKmsConfig.Region,
+			Profile:         c.AwsKmsConfig.Profile,
+			Endpoint:        c.AwsKmsConfig.Endpoint,
+			CertificateFile: c.AwsKmsConfig.CertificateFile,
+		}, ctx, timestamper)
+	case TokenTypeGcpKms:
+		return gcpkms.NewKmsKey(gcpkms.Options{
+			KeyName:           c.GcpKmsConfig.KeyName,
+			CredentialsFile:   c.GcpKmsConfig.CredentialsFile,
+			CredentialsJSON:   c.GcpKmsConfig.CredentialsJSON,
+			Endpoint:          c.GcpKmsConfig.Endpoint,
+			Insecure:          c.GcpKmsConfig.Insecure,
+			CertificateFile:   c.GcpKmsConfig.CertificateFile,
+			CertificateSecret: c.GcpKmsConfig.CertificateSecret,
+			SecretEndpoint:    c.GcpKmsConfig.SecretEndpoint,
+		}, ctx, timestamper)
+	case TokenTypeCertificate, "":
+	default:
+		return nil, sigerr.ConfigError{Err: fmt.Errorf("unknown token type %q", c.TokenType)}
+	}
+
+	signerCert, err = c.CertConfig.Load()
+	if err != nil {
+		return nil, err
+	}
+	signerCert.Timestamper = timestamper
+
+	return signerCert, nil
+}
+
+// Settings that only apply when signing one type of file
+type FormatConfig struct {
+	HashAlgorithm string `json:"hashAlgorithm,omitempty" yaml:"hashAlgorithm,omitempty" mapstructure:"hashAlgorithm"`
+}
+
+type AzureConfig struct {
+	VaultUrl           string `json:"vaultUrl" yaml:"vaultUrl" mapstructure:"vaultUrl"`
+	TenantId           string `json:"tenantId" yaml:"tenantId" mapstructure:"tenantId"`
+	ClientId           string `json:"clientId" yaml:"clientId" mapstructure:"clientId"`
+	ClientSecret       string `json:"clientSecret" yaml:"clientSecret" mapstructure:"clientSecret"`
+	CertificateName    string `json:"certificateName" yaml:"certificateName" mapstructure:"certificateName"`
+	CertificateVersion string `json:"certificateVersion,omitempty" yaml:"certificateVersion,omitempty" mapstructure:"certificateVersion"`
+
+	// How to authenticate, see azure.AuthMethod. Default is clientSecret when
+	// a secret is given and the DefaultAzureCredential chain otherwise.
+	AuthMethod                azure.AuthMethod `json:"authMethod,omitempty" yaml:"authMethod,omitempty" mapstructure:"authMethod"`
+	ClientCertificate         string           `json:"clientCertificate,omitempty" yaml:"clientCertificate,omitempty" mapstructure:"clientCertificate"`
+	ClientCertificatePassword string           `json:"clientCertificatePassword,omitempty" yaml:"clientCertificatePassword,omitempty" mapstructure:"clientCertificatePassword"`
+	FederatedTokenFile        string           `json:"federatedTokenFile,omitempty" yaml:"federatedTokenFile,omitempty" mapstructure:"federatedTokenFile"`
+}
+
+func (c AzureConfig) credentialOptions() azure.CredentialOptions {
+	return azure.CredentialOptions{
+		Method:                    c.AuthMethod,
+		TenantId:                  c.TenantId,
+		ClientId:                  c.ClientId,
+		ClientSecret:              c.ClientSecret,
+		ClientCertificate:         c.ClientCertificate,
+		ClientCertificatePassword: c.ClientCertificatePassword,
+		FederatedTokenFile:        c.FederatedTokenFile,
+	}
+}
+
+type AzureTrustedConfig struct {
+	Region       string `json:"region" yaml:"region" mapstructure:"region"`
+	TenantId     string `json:"tenantId" yaml:"tenantId" mapstructure:"tenantId"`
+	ClientId     string `json:"clientId" yaml:"clientId" mapstructure:"clientId"`
+	ClientSecret string `json:"clientSecret" yaml:"clientSecret" mapstructure:"clientSecret"`
+	Account      string `json:"account" yaml:"account" mapstructure:"account"`
+	Profile      string `json:"profile" yaml:"profile" mapstructure:"profile"`
+
+	// Same as for AzureConfig
+	AuthMethod                azure.AuthMethod `json:"authMethod,omitempty" yaml:"authMethod,omitempty" mapstructure:"authMethod"`
+	ClientCertificate         string           `json:"clientCertificate,omitempty" yaml:"clientCertificate,omitempty" mapstructure:"clientCertificate"`
+	ClientCertificatePassword string           `json:"clientCertificatePassword,omitempty" yaml:"clientCertificatePassword,omitempty" mapstructure:"clientCertificatePassword"`
+	FederatedTokenFile        string           `json:"federatedTokenFile,omitempty" yaml:"federatedTokenFile,omitempty" mapstructure:"federatedTokenFile"`
+
+	// Seconds the certificate chain is cached on disk, 6 hours by default
+	CertificateCacheTtl int  `json:"certificateCacheTtl,omitempty" yaml:"certificateCacheTtl,omitempty" mapstructure:"certificateCacheTtl"`
+	NoCertificateCache  bool `json:"noCertificateCache,omitempty" yaml:"noCertificateCache,omitempty" mapstructure:"noCertificateCache"`
+}
+
+func (c AzureTrustedConfig) credentialOptions() azure.CredentialOptions {
+	return azure.CredentialOptions{
+		Method:                    c.AuthMethod,
+		TenantId:                  c.TenantId,
+		ClientId:                  c.ClientId,
+		ClientSecret:              c.ClientSecret,
+		ClientCertificate:         c.ClientCertificate,
+		ClientCertificatePassword: c.ClientCertificatePassword,
+		FederatedTokenFile:        c.FederatedTokenFile,
+	}
+}
+
+type CertConfig struct {
+	Certificate string `json:"certificate" yaml:"certificate" mapstructure:"certificate"`
+	PrivateKey  string `json:"privateKey" yaml:"privateKey" mapstructure:"privateKey"`
+	// Paths to PEM files, used when the inline values above are empty
+	CertificateFile string `json:"certificateFile,omitempty" yaml:"certificateFile,omitempty" mapstructure:"certificateFile"`
+	PrivateKeyFile  string `json:"privateKeyFile,omitempty" yaml:"privateKeyFile,omitempty" mapstructure:"privateKeyFile"`
+	// PKCS#12 (.pfx) bundle with key and chain, as base64 or a file path.
+	// Takes precedence over the PEM settings.
+	Pkcs12     string `json:"pkcs12,omitempty" yaml:"pkcs12,omitempty" mapstructure:"pkcs12"`
+	Pkcs12File string `json:"pkcs12File,omitempty" yaml:"pkcs12File,omitempty" mapstructure:"pkcs12File"`
+	// Passphrase of the private key or PKCS#12 bundle, read from the first of
+	// these that is set. Without any, the user is asked on the terminal.
+	Passphrase        string `json:"passphrase,omitempty" yaml:"passphrase,omitempty" mapstructure:"passphrase"`
+	PassphraseEnv     string `json:"passphraseEnv,omitempty" yaml:"passphraseEnv,omitempty" mapstructure:"passphraseEnv"`
+	PassphraseFile    string `json:"passphraseFile,omitempty" yaml:"passphraseFile,omitempty" mapstructure:"passphraseFile"`
+	PassphraseCommand string `json:"passphraseCommand,omitempty" yaml:"passphraseCommand,omitempty" mapstructure:"passphraseCommand"`
+}
+
+type Pkcs11Config struct {
+	Module      string `json:"module" yaml:"module" mapstructure:"module"`
+	Slot        *uint  `json:"slot,omitempty" yaml:"slot,omitempty" mapstructure:"slot"`
+	TokenLabel  string `json:"tokenLabel,omitempty" yaml:"tokenLabel,omitempty" mapstructure:"tokenLabel"`
+	TokenSerial string `json:"tokenSerial,omitempty" yaml:"tokenSerial,omitempty" mapstructure:"tokenSerial"`
+	KeyLabel    string `json:"keyLabel,omitempty" yaml:"keyLabel,omitempty" mapstructure:"keyLabel"`
+	KeyId       string `json:"keyId,omitempty" yaml:"keyId,omitempty" mapstructure:"keyId"`
+	// The PIN is read from the first of these that is set
+	Pin     string `json:"pin,omitempty" yaml:"pin,omitempty" mapstructure:"pin"`
+	PinEnv  string `json:"pinEnv,omitempty" yaml:"pinEnv,omitempty" mapstructure:"pinEnv"`
+	PinFile string `json:"pinFile,omitempty" yaml:"pinFile,omitempty" mapstructure:"pinFile"`
+	// PEM file with the certificate chain, if it is not stored on the token
+	CertificateFile string `json:"certificateFile,omitempty" yaml:"certificateFile,omitempty" mapstructure:"certificateFile"`
+}
+
+type VaultTransitConfig struct {
+	// Default is the VAULT_ADDR environment variable
+	Address   string `json:"address,omitempty" yaml:"address,omitempty" mapstructure:"address"`
+	Namespace string `json:"namespace,omitempty" yaml:"namespace,omitempty" mapstructure:"namespace"`
+	// Mount path of the Transit engine, default "transit"
+	Mount      string `json:"mount,omitempty" yaml:"mount,omitempty" mapstructure:"mount"`
+	KeyName    string `json:"keyName" yaml:"keyName" mapstructure:"keyName"`
+	KeyVersion int    `json:"keyVersion,omitempty" yaml:"keyVersion,omitempty" mapstructure:"keyVersion"`
+
+	// Token authentication. Default is the VAULT_TOKEN environment variable.
+	Token     string `json:"token,omitempty" yaml:"token,omitempty" mapstructure:"token"`
+	TokenEnv  string `json:"tokenEnv,omitempty" yaml:"tokenEnv,omitempty" mapstructure:"tokenEnv"`
+	TokenFile string `json:"tokenFile,omitempty" yaml:"tokenFile,omitempty" mapstructure:"tokenFile"`
+
+	// AppRole authentication, used instead of a token when roleId is set
+	RoleId       string `json:"roleId,omitempty" yaml:"roleId,omitempty" mapstructure:"roleId"`
+	SecretId     string `json:"secretId,omitempty" yaml:"secretId,omitempty" mapstructure:"secretId"`
+	SecretIdEnv  string `json:"secretIdEnv,omitempty" yaml:"secretIdEnv,omitempty" mapstructure:"secretIdEnv"`
+	SecretIdFile string `json:"secretIdFile,omitempty" yaml:"secretIdFile,omitempty" mapstructure:"secretIdFile"`
+	AppRoleMount string `json:"appRoleMount,omitempty" yaml:"appRoleMount,omitempty" mapstructure:"appRoleMount"`
+
+	// The certificate chain, from a PEM file or a field of a KV secret
+	CertificateFile    string `json:"certificateFile,omitempty" yaml:"certificateFile,omitempty" mapstructure:"certificateFile"`
+	CertificateKvPath  string `json:"certificateKvPath,omitempty" yaml:"certificateKvPath,omitempty" mapstructure:"certificateKvPath"`
+	CertificateKvField string `json:"certificateKvField,omitempty" yaml:"certificateKvField,omitempty" mapstructure:"certificateKvField"`
+}
+
+func (c VaultTransitConfig) getSigner(timestamper pkcs9.Timestamper, ctx context.Context) (*certloader.Certificate, error) {
+	opts := vault.Options{
+		Address:            c.Address,
+		Namespace:          c.Namespace,
+		Mount:              c.Mount,
+		KeyName:            c.KeyName,
+		KeyVersion:         c.KeyVersion,
+		RoleId:             c.RoleId,
+		AppRoleMount:       c.AppRoleMount,
+		CertificateFile:    c.CertificateFile,
+		CertificateKvPath:  c.CertificateKvPath,
+		CertificateKvField: c.CertificateKvField,
+	}
+	if opts.Address == "" {
+		opts.Address = os.Getenv("VAULT_ADDR")
+	}
+
+	var err error
+	if c.RoleId != "" {
+		if opts.SecretId, _, err = readSecret(c.SecretId, c.SecretIdEnv, c.SecretIdFile, "", "AppRole secret ID"); err != nil {
+			return nil, err
+		}
+	} else {
+		var ok bool
+		if opts.Token, ok, err = readSecret(c.Token, c.TokenEnv, c.TokenFile, "", "Vault token"); err != nil {
+			return nil, err
+		} else if !ok {
+			opts.Token = os.Getenv("VAULT_TOKEN")
+		}
+	}
+
+	return vault.NewVaultTransitKey(opts, ctx, timestamper)
+}
+
+// Credentials are taken from the standard AWS chain
+type AwsKmsConfig struct {
+	// Key ID, ARN or alias, e.g. alias/codesign
+	KeyId           string `json:"keyId" yaml:"keyId" mapstructure:"keyId"`
+	Region          string `json:"region,omitempty" yaml:"region,omitempty" mapstructure:"region"`
+	Profile         string `json:"profile,omitempty" yaml:"profile,omitempty" mapstructure:"profile"`
+	Endpoint        string `json:"endpoint,omitempty" yaml:"endpoint,omitempty" mapstructure:"endpoint"`
+	CertificateFile string `json:"certificateFile" yaml:"certificateFile" mapstructure:"certificateFile"`
+}
+
+// Without credentials, Application Default Credentials are used, which
+// includes workload identity on GKE and in CI
+type GcpKmsConfig struct {
+	// Full resource name of the key version
+	KeyName         string `json:"keyName" yaml:"keyName" mapstructure:"keyName"`
+	CredentialsFile string `json:"credentialsFile,omitempty" yaml:"credentialsFile,omitempty" mapstructure:"credentialsFile"`
+	CredentialsJSON string `json:"credentialsJson,omitempty" yaml:"credentialsJson,omitempty" mapstructure:"credentialsJson"`
+	Endpoint        string `json:"endpoint,omitempty" yaml:"endpoint,omitempty" mapstructure:"endpoint"`
+	// Connect to the endpoints without TLS or authentication, for emulators
+	Insecure bool `json:"insecure,omitempty" yaml:"insecure,omitempty" mapstructure:"insecure"`
+	// The certificate chain, from a PEM file or a Secret Manager secret version
+	CertificateFile   string `json:"certificateFile,omitempty" yaml:"certificateFile,omitempty" mapstructure:"certificateFile"`
+	CertificateSecret string `json:"certificateSecret,omitempty" yaml:"certificateSecret,omitempty" mapstructure:"certificateSecret"`
+	SecretEndpoint    string `json:"secretEndpoint,omitempty" yaml:"secretEndpoint,omitempty" mapstructure:"secretEndpoint"`
+}
+
+func (c Pkcs11Config) GetPin() (string, error) {
+	pin, _, err := readSecret(c.Pin, c.PinEnv, c.PinFile, "", "PIN")
+	return pin, err
+}
@@ -0,0 +1,167 @@
+package ossign
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/ossign/ossign/pkg/authenticode"
+	"github.com/ossign/ossign/pkg/sigerr"
+)
+
+var (
+	ErrUnsupportedFile = errors.New("unsupported file type")
+	ErrAmbiguousFile   = errors.New("ambiguous file type")
+)
+
+var (
+	comdocMagic = []byte{0xd0, 0xcf, 0x11, 0xe0, 0xa1, 0xb1, 0x1a, 0xe1}
+	zipMagic    = []byte{0x50, 0x4b, 0x03, 0x04}
+	cabMagic    = []byte("MSCF")
+	udifMagic   = []byte("koly")
+)
+
+// DetectSignatureType inspects the contents of a file and returns the
+// signature type able to sign it. PowerShell files have no magic number and
+// are detected by their extension instead.
+func DetectSignatureType(f io.ReaderAt, size int64, filename string) (SignatureType, error) {
+	header := make([]byte, 512)
+	n, err := f.ReadAt(header, 0)
+	if err != nil && err != io.EOF {
+		return "", fmt.Errorf("reading file header: %w", err)
+	}
+	header = header[:n]
+
+	var candidates []SignatureType
+	if isPE(f, header) {
+		candidates = append(candidates, PecoffSignature)
+	}
+	if bytes.HasPrefix(header, comdocMagic) {
+		candidates = append(candidates, MsiSignature)
+	}
+	if bytes.HasPrefix(header, cabMagic) {
+		candidates = append(candidates, CabSignature)
+	}
+	if bytes.HasPrefix(header, zipMagic) && isAppx(f, size) {
+		candidates = append(candidates, AppxSignature)
+	}
+	// APKs signed with the v1 scheme carry a JAR manifest as well
+	if bytes.HasPrefix(header, zipMagic) && isApk(f, size) {
+		candidates = append(candidates, ApkSignature)
+	} else if bytes.HasPrefix(header, zipMagic) && isJar(f, size) {
+		candidates = append(candidates, JarSignature)
+	}
+	if isUDIF(f, size) {
+		candidates = append(candidates, DmgSignature)
+	}
+	if isMachO(header) {
+		candidates = append(candidates, MachosSignature)
+	}
+	if bytes.Contains(header, []byte("<assembly")) || bytes.Contains(header, []byte(":assembly")) {
+		candidates = append(candidates, AppmanifestSignature)
+	}
+	if _, ok := authenticode.GetSigStyle(filename); ok {
+		candidates = append(candidates, PowershellSignature)
+	}
+
+	switch len(candidates) {
+	case 0:
+		return "", sigerr.FormatError{Err: fmt.Errorf("%w: %s", ErrUnsupportedFile, filename)}
+	case 1:
+		return candidates[0], nil
+	default:
+		names := make([]string, len(candidates))
+		for i, c := range candidates {
+			names[i] = string(c)
+		}
+		return "", sigerr.FormatError{Err: fmt.Errorf("%w: %s matches %s, use -t to select one", ErrAmbiguousFile, filename, strings.Join(names, ", "))}
+	}
+}
+
+// MZ header followed by a PE signature at the offset given by e_lfanew
+func isPE(f io.ReaderAt, header []byte) bool {
+	if len(header) < 0x40 || !bytes.HasPrefix(header, []byte("MZ")) {
+		return false
+	}
+	peStart := int64(binary.LittleEndian.Uint32(header[0x3c:0x40]))
+	sig := make([]byte, 4)
+	if _, err := f.ReadAt(sig, peStart); err != nil {
+		return false
+	}
+	return bytes.Equal(sig, []byte("PE\x00\x00"))
+}
+
+// zip archive holding an APPX or APPX bundle manifest
+func isAppx(f io.ReaderAt, size int64) bool {
+	zr, err := zip.NewReader(f, size)
+	if err != nil {
+		return false
+	}
+	for _, zf := range zr.File {
+		switch path.Clean(strings.TrimPrefix(zf.Name, "/")) {
+		case "AppxManifest.xml", "AppxMetadata/AppxBundleManifest.xml":
+			return true
+		}
+	}
+	return false
+}
+
+// zip archive holding a JAR manifest
+func isJar(f io.ReaderAt, size int64) bool {
+	zr, err := zip.NewReader(f, size)
+	if err != nil {
+		return false
+	}
+	for _, zf := range zr.File {
+		if zf.Name == "META-INF/MANIFEST.MF" {
+			return true
+		}
+	}
+	return false
+}
+
+// zip archive holding an Android manifest
+func isApk(f io.ReaderAt, size int64) bool {
+	zr, err := zip.NewReader(f, size)
+	if err != nil {
+		return false
+	}
+	for _, zf := range zr.File {
+		if zf.Name == "AndroidManifest.xml" {
+			return true
+		}
+	}
+	return false
+}
+
+// UDIF images end with a 512 byte "koly" trailer
+func isUDIF(f io.ReaderAt, size int64) bool {
+	if size < 512 {
+		return false
+	}
+	magic := make([]byte, len(udifMagic))
+	if _, err := f.ReadAt(magic, size-512); err != nil {
+		return false
+	}
+	return bytes.Equal(magic, udifMagic)
+}
+
+func isMachO(header []byte) bool {
+	if len(header) < 8 {
+		return false
+	}
+	switch binary.BigEndian.Uint32(header) {
+	case 0xfeedface, 0xfeedfacf, 0xcefaedfe, 0xcffaedfe:
+		return true
+	case 0xcafebabe:
+		// Java class files share the fat magic; their version number takes the
+		// place of the architecture count and is always much larger.
+		return binary.BigEndian.Uint32(header[4:]) < 20
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+package ossign
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func fakePE() []byte {
+	blob := make([]byte, 0x100)
+	copy(blob, "MZ")
+	binary.LittleEndian.PutUint32(blob[0x3c:], 0x80)
+	copy(blob[0x80:], "PE\x00\x00")
+	return blob
+}
+
+func fakeAppx(t *testing.T) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create("AppxManifest.xml")
+	require.NoError(t, err)
+	_, err = w.Write([]byte("<Package/>"))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+func fakeJar(t *testing.T) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create("META-INF/MANIFEST.MF")
+	require.NoError(t, err)
+	_, err = w.Write([]byte("Manifest-Version: 1.0\r\n\r\n"))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+func fakeApk(t *testing.T) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range []string{"AndroidManifest.xml", "META-INF/MANIFEST.MF"} {
+		w, err := zw.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte("Manifest-Version: 1.0\r\n\r\n"))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+func fakeDmg() []byte {
+	blob := make([]byte, 4096)
+	copy(blob[len(blob)-512:], "koly")
+	return blob
+}
+
+func TestDetectSignatureType(t *testing.T) {
+	cases := []struct {
+		name     string
+		filename string
+		data     []byte
+		expected SignatureType
+	}{
+		{"pecoff", "app.exe", fakePE(), PecoffSignature},
+		{"msi", "setup.msi", append(append([]byte{}, comdocMagic...), make([]byte, 504)...), MsiSignature},
+		{"cab", "payload.cab", []byte("MSCF\x00\x00\x00\x00"), CabSignature},
+		{"appx", "app.msix", fakeAppx(t), AppxSignature},
+		{"jar", "app.jar", fakeJar(t), JarSignature},
+		{"apk", "app.apk", fakeApk(t), ApkSignature},
+		{"dmg", "app.dmg", fakeDmg(), DmgSignature},
+		{"machos", "app", []byte{0xcf, 0xfa, 0xed, 0xfe, 7, 0, 0, 1}, MachosSignature},
+		{"appmanifest", "app.manifest", []byte(`<?xml version="1.0"?><assembly xmlns="urn:schemas-microsoft-com:asm.v1"/>`), AppmanifestSignature},
+		{"powershell", "script.ps1", []byte("Write-Host 'hello'\r\n"), PowershellSignature},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			detected, err := DetectSignatureType(bytes.NewReader(c.data), int64(len(c.data)), c.filename)
+			require.NoError(t, err)
+			assert.Equal(t, c.expected, detected)
+		})
+	}
+}
+
+func TestDetectSignatureTypeErrors(t *testing.T) {
+	text := []byte("just some text")
+	_, err := DetectSignatureType(bytes.NewReader(text), int64(len(text)), "notes.txt")
+	assert.ErrorIs(t, err, ErrUnsupportedFile)
+
+	// Java class files share the Mach-O fat magic
+	class := []byte{0xca, 0xfe, 0xba, 0xbe, 0, 0, 0, 52}
+	_, err = DetectSignatureType(bytes.NewReader(class), int64(len(class)), "Main.class")
+	assert.ErrorIs(t, err, ErrUnsupportedFile)
+
+	pe := fakePE()
+	_, err = DetectSignatureType(bytes.NewReader(pe), int64(len(pe)), "script.ps1")
+	assert.ErrorIs(t, err, ErrAmbiguousFile)
+}
@@ -0,0 +1,110 @@
+package ossign
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/ossign/ossign/pkg/sigerr"
+	"github.com/sassoftware/relic/v8/lib/certloader"
+)
+
+// Parameter name for the digest algorithm used for signing
+const ParamHashAlgorithm = "hashAlgorithm"
+
+var hashAlgorithms = map[string]crypto.Hash{
+	// only for legacy Authenticode signatures, usually with a SHA-256 one
+	// appended
+	"sha1":   crypto.SHA1,
+	"sha256": crypto.SHA256,
+	"sha384": crypto.SHA384,
+	"sha512": crypto.SHA512,
+}
+
+// Parse a digest algorithm name such as "sha384" or "SHA-384"
+func ParseHashAlgorithm(name string) (crypto.Hash, error) {
+	hash, ok := hashAlgorithms[strings.ReplaceAll(strings.ToLower(name), "-", "")]
+	if !ok {
+		return 0, sigerr.ConfigError{Err: fmt.Errorf("unsupported hash algorithm %q, expected one of sha1, sha256, sha384 or sha512", name)}
+	}
+	return hash, nil
+}
+
+// Return the digest algorithm to sign with, SHA-256 if none is configured.
+// Signer fills in the default of its key before.
+func (o *SignOptions) HashFunc() (crypto.Hash, error) {
+	name := o.GetParamDefault(ParamHashAlgorithm, "")
+	if name == "" {
+		return crypto.SHA256, nil
+	}
+	return ParseHashAlgorithm(name)
+}
+
+// Keys of remote services that only sign some digests implement
+// HashLimitedKey, so that other digests are rejected before signing.
+type HashLimitedKey interface {
+	SupportedHashes() []crypto.Hash
+}
+
+// Check that a key can produce signatures over the given digest. ECDSA keys
+// are used with the digest matching their curve, and keys implementing
+// HashLimitedKey with the digests they report.
+func checkKeyHash(cert *certloader.Certificate, hash crypto.Hash) error {
+	if key, ok := cert.Leaf.PublicKey.(*ecdsa.PublicKey); ok {
+		expected := curveHash(key.Curve)
+		if expected == 0 {
+			return sigerr.ConfigError{Err: fmt.Errorf("unsupported ECDSA curve %s", key.Curve.Params().Name)}
+		}
+		if hash != expected {
+			return sigerr.ConfigError{Err: fmt.Errorf("%s keys must be used with %s, not %s", key.Curve.Params().Name, expected, hash)}
+		}
+	}
+	if key, ok := cert.PrivateKey.(HashLimitedKey); ok {
+		supported := key.SupportedHashes()
+		if !slices.Contains(supported, hash) {
+			names := make([]string, len(supported))
+			for i, h := range supported {
+				names[i] = h.String()
+			}
+			return sigerr.ConfigError{Err: fmt.Errorf("the signing key only supports %s, not %s", strings.Join(names, ", "), hash)}
+		}
+	}
+	return nil
+}
+
+// The digest a key signs with when none is configured: the one matching the
+// curve of ECDSA keys, the first one a HashLimitedKey supports, and SHA-256
+// for any other key
+func defaultKeyHash(cert *certloader.Certificate) crypto.Hash {
+	if key, ok := cert.Leaf.PublicKey.(*ecdsa.PublicKey); ok {
+		if hash := curveHash(key.Curve); hash != 0 {
+			return hash
+		}
+	}
+	if key, ok := cert.PrivateKey.(HashLimitedKey); ok {
+		if supported := key.SupportedHashes(); len(supported) > 0 {
+			return supported[0]
+		}
+	}
+	return crypto.SHA256
+}
+
+func curveHash(curve elliptic.Curve) crypto.Hash {
+	switch curve {
+	case elliptic.P256():
+		return crypto.SHA256
+	case elliptic.P384():
+		return crypto.SHA384
+	case elliptic.P521():
+		return crypto.SHA512
+	}
+	return 0
+}
+
+// Name of a digest algorithm as accepted by ParseHashAlgorithm
+func hashAlgorithmName(hash crypto.Hash) string {
+	return strings.ToLower(strings.ReplaceAll(hash.String(), "-", ""))
+}
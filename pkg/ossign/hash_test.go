@@ -0,0 +1,115 @@
+package ossign
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"errors"
+	"testing"
+
+	"github.com/ossign/ossign/pkg/sigerr"
+	"github.com/sassoftware/relic/v8/lib/certloader"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseHashAlgorithm(t *testing.T) {
+	for name, expected := range map[string]crypto.Hash{
+		"sha256":  crypto.SHA256,
+		"SHA-384": crypto.SHA384,
+		"Sha512":  crypto.SHA512,
+	} {
+		hash, err := ParseHashAlgorithm(name)
+		require.NoError(t, err, name)
+		assert.Equal(t, expected, hash, name)
+	}
+
+	_, err := ParseHashAlgorithm("md5")
+	assert.True(t, errors.As(err, &sigerr.ConfigError{}))
+}
+
+// A remote key that only signs SHA-256 digests
+type sha256Key struct {
+	crypto.Signer
+}
+
+func (sha256Key) SupportedHashes() []crypto.Hash { return []crypto.Hash{crypto.SHA256} }
+
+func TestCheckKeyHash(t *testing.T) {
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	p384Cert := &certloader.Certificate{Leaf: &x509.Certificate{PublicKey: &p384.PublicKey}, PrivateKey: p384}
+	assert.NoError(t, checkKeyHash(p384Cert, crypto.SHA384))
+	assert.Error(t, checkKeyHash(p384Cert, crypto.SHA256))
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	rsaCert := &certloader.Certificate{Leaf: &x509.Certificate{PublicKey: &rsaKey.PublicKey}, PrivateKey: rsaKey}
+	assert.NoError(t, checkKeyHash(rsaCert, crypto.SHA512))
+
+	rsaCert.PrivateKey = sha256Key{rsaKey}
+	assert.NoError(t, checkKeyHash(rsaCert, crypto.SHA256))
+	err = checkKeyHash(rsaCert, crypto.SHA1)
+	assert.True(t, errors.As(err, &sigerr.ConfigError{}))
+}
+
+func TestFormatHashAlgorithm(t *testing.T) {
+	s := &Signer{Config: &SigningConfig{
+		HashAlgorithm: "sha384",
+		Formats: map[SignatureType]FormatConfig{
+			MsiSignature: {HashAlgorithm: "sha256"},
+		},
+	}}
+
+	hash, err := s.mergeOptions(PecoffSignature, nil).HashFunc()
+	require.NoError(t, err)
+	assert.Equal(t, crypto.SHA384, hash)
+
+	hash, err = s.mergeOptions(MsiSignature, nil).HashFunc()
+	require.NoError(t, err)
+	assert.Equal(t, crypto.SHA256, hash)
+
+	hash, err = s.mergeOptions(MsiSignature, &SignOptions{Params: map[string]string{ParamHashAlgorithm: "sha512"}}).HashFunc()
+	require.NoError(t, err)
+	assert.Equal(t, crypto.SHA512, hash)
+
+	hash, err = (&SignOptions{}).HashFunc()
+	require.NoError(t, err)
+	assert.Equal(t, crypto.SHA256, hash)
+}
+
+// A remote key like a Cloud KMS EC_SIGN_P384_SHA384 key version
+type sha384Key struct {
+	crypto.Signer
+}
+
+func (sha384Key) SupportedHashes() []crypto.Hash { return []crypto.Hash{crypto.SHA384} }
+
+func TestDefaultKeyHash(t *testing.T) {
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	cert := &certloader.Certificate{Leaf: &x509.Certificate{PublicKey: &p384.PublicKey}, PrivateKey: p384}
+
+	// without a configured digest, the one of the curve is used
+	s := &Signer{Config: &SigningConfig{}, Cert: cert}
+	hash, err := s.mergeOptions(PecoffSignature, nil).HashFunc()
+	require.NoError(t, err)
+	assert.Equal(t, crypto.SHA384, hash)
+	assert.NoError(t, checkKeyHash(cert, hash))
+
+	// a configured digest the key cannot use is still rejected
+	s.Config.HashAlgorithm = "sha256"
+	hash, err = s.mergeOptions(PecoffSignature, nil).HashFunc()
+	require.NoError(t, err)
+	assert.Error(t, checkKeyHash(cert, hash))
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	rsaCert := &certloader.Certificate{Leaf: &x509.Certificate{PublicKey: &rsaKey.PublicKey}, PrivateKey: rsaKey}
+	assert.Equal(t, crypto.SHA256, defaultKeyHash(rsaCert))
+	rsaCert.PrivateKey = sha384Key{rsaKey}
+	assert.Equal(t, crypto.SHA384, defaultKeyHash(rsaCert))
+}
@@ -0,0 +1,307 @@
+package ossign
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/ossign/ossign/pkg/sigerr"
+)
+
+// OSAttr for INF files whose decorations name no Windows version
+const DefaultDriverOSAttr = "2:10.0"
+
+// The files of a driver package as referenced by its INF file
+type DriverPackage struct {
+	Inf string
+	// Files listed in the SourceDisksFiles sections
+	Files []string
+	// The PE images among Files, which are signed themselves
+	Binaries []string
+	// Catalog files named by the CatalogFile entries
+	Catalogs []string
+	// Windows versions from the target OS decorations, in catalog form
+	OSAttr string
+}
+
+// A section of an INF file, with the values of each line split at commas
+type infSection struct {
+	keys   []string
+	values [][]string
+}
+
+// Read the files, catalogs and target versions of a driver package from its
+// INF file
+func ParseInf(path string) (*DriverPackage, error) {
+	blob, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	sections, err := parseInf(blob)
+	if err != nil {
+		return nil, sigerr.FormatError{Err: fmt.Errorf("parsing %s: %w", path, err)}
+	}
+	dir := filepath.Dir(path)
+	pkg := &DriverPackage{Inf: path}
+
+	// catalogs, possibly one per platform as in CatalogFile.NTamd64
+	seen := make(map[string]bool)
+	version := sections["version"]
+	for i, key := range version.keys {
+		key = strings.ToLower(key)
+		if key != "catalogfile" && !strings.HasPrefix(key, "catalogfile.") || len(version.values[i]) == 0 {
+			continue
+		}
+		if cat := filepath.Join(dir, version.values[i][0]); !seen[cat] {
+			seen[cat] = true
+			pkg.Catalogs = append(pkg.Catalogs, cat)
+		}
+	}
+	if len(pkg.Catalogs) == 0 {
+		return nil, sigerr.FormatError{Err: fmt.Errorf("%s has no CatalogFile entry", path)}
+	}
+
+	var names []string
+	for name := range sections {
+		if name == "sourcedisksfiles" || strings.HasPrefix(name, "sourcedisksfiles.") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	seen = make(map[string]bool)
+	for _, name := range names {
+		section := sections[name]
+		for i, key := range section.keys {
+			var disk, subdir string
+			if values := section.values[i]; len(values) > 0 {
+				disk = values[0]
+				if len(values) > 1 {
+					subdir = values[1]
+				}
+			}
+			decoration := strings.TrimPrefix(name, "sourcedisksfiles")
+			file := filepath.Join(dir, infPath(diskPath(sections, decoration, disk)), infPath(subdir), key)
+			if seen[file] {
+				continue
+			}
+			seen[file] = true
+			f, err := os.Open(file)
+			if err != nil {
+				return nil, sigerr.FormatError{Err: fmt.Errorf("file referenced by %s: %w", path, err)}
+			}
+			header := make([]byte, 512)
+			n, _ := f.ReadAt(header, 0)
+			if isPE(f, header[:n]) {
+				pkg.Binaries = append(pkg.Binaries, file)
+			}
+			f.Close()
+			pkg.Files = append(pkg.Files, file)
+		}
+	}
+
+	pkg.OSAttr = osAttr(sections["manufacturer"])
+	return pkg, nil
+}
+
+// Directory below the INF of a disk ID used in the SourceDisksFiles section
+// with the given decoration, such as ".amd64". Decorated sections look in the
+// SourceDisksNames section of the same decoration before the undecorated one.
+func diskPath(sections map[string]infSection, decoration, disk string) string {
+	names := []string{"sourcedisksnames"}
+	if decoration != "" {
+		names = []string{"sourcedisksnames" + decoration, "sourcedisksnames"}
+	}
+	for _, name := range names {
+		section := sections[name]
+		for i, key := range section.keys {
+			if key != disk {
+				continue
+			}
+			if values := section.values[i]; len(values) >= 4 {
+				return values[3]
+			}
+			return ""
+		}
+	}
+	return ""
+}
+
+// Directories in INF files use backslashes and may start with one
+func infPath(p string) string {
+	return filepath.FromSlash(strings.Trim(strings.ReplaceAll(p, "\\", "/"), "/"))
+}
+
+// Collect the Windows versions of target OS decorations such as
+// NTamd64.10.0...16299 into an OSAttr like 2:10.0
+func osAttr(manufacturer infSection) string {
+	seen := make(map[string]bool)
+	var versions []string
+	for _, values := range manufacturer.values {
+		// the first value is the models section, the others its decorations
+		for _, decoration := range values[1:] {
+			parts := strings.Split(decoration, ".")
+			if len(parts) < 2 || !strings.HasPrefix(strings.ToLower(parts[0]), "nt") {
+				continue
+			}
+			major, err := strconv.Atoi(parts[1])
+			if err != nil {
+				continue
+			}
+			minor := 0
+			if len(parts) > 2 && parts[2] != "" {
+				if minor, err = strconv.Atoi(parts[2]); err != nil {
+					continue
+				}
+			}
+			version := fmt.Sprintf("2:%d.%d", major, minor)
+			if !seen[version] {
+				seen[version] = true
+				versions = append(versions, version)
+			}
+		}
+	}
+	if len(versions) == 0 {
+		return DefaultDriverOSAttr
+	}
+	return strings.Join(versions, ",")
+}
+
+// Split an INF file into sections with lower case names. Comments
+// and line continuations are removed and %strkey% tokens are replaced from
+// the Strings sections.
+func parseInf(blob []byte) (map[string]infSection, error) {
+	text, err := decodeInf(blob)
+	if err != nil {
+		return nil, err
+	}
+
+	sections := make(map[string]infSection)
+	current := ""
+	var pending string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(stripInfComment(strings.TrimRight(line, "\r")))
+		if strings.HasSuffix(line, "\\") {
+			pending += strings.TrimSuffix(line, "\\")
+			continue
+		}
+		line, pending = pending+line, ""
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			end := strings.Index(line, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated section name %q", line)
+			}
+			current = strings.ToLower(strings.TrimSpace(line[1:end]))
+			if _, ok := sections[current]; !ok {
+				sections[current] = infSection{}
+			}
+			continue
+		}
+		if current == "" {
+			return nil, errors.New("entry outside of any section")
+		}
+		key, value := "", line
+		if i := strings.Index(line, "="); i >= 0 {
+			key, value = strings.TrimSpace(line[:i]), line[i+1:]
+		}
+		var values []string
+		for _, v := range strings.Split(value, ",") {
+			values = append(values, strings.Trim(strings.TrimSpace(v), "\""))
+		}
+		section := sections[current]
+		section.keys = append(section.keys, key)
+		section.values = append(section.values, values)
+		sections[current] = section
+	}
+
+	strs := make(map[string]string)
+	for name, section := range sections {
+		if name != "strings" && !strings.HasPrefix(name, "strings.") {
+			continue
+		}
+		for i, key := range section.keys {
+			if _, ok := strs[strings.ToLower(key)]; !ok || name == "strings" {
+				strs[strings.ToLower(key)] = strings.Join(section.values[i], ",")
+			}
+		}
+	}
+	for _, section := range sections {
+		for i, values := range section.values {
+			for j, v := range values {
+				values[j] = expandInfStrings(v, strs)
+			}
+			section.keys[i] = expandInfStrings(section.keys[i], strs)
+		}
+	}
+	return sections, nil
+}
+
+// INF files are ANSI, UTF-8 or UTF-16LE with a byte order mark
+func decodeInf(blob []byte) (string, error) {
+	switch {
+	case bytes.HasPrefix(blob, []byte{0xff, 0xfe}):
+		blob = blob[2:]
+		if len(blob)%2 != 0 {
+			return "", errors.New("truncated UTF-16 text")
+		}
+		words := make([]uint16, len(blob)/2)
+		for i := range words {
+			words[i] = binary.LittleEndian.Uint16(blob[i*2:])
+		}
+		return string(utf16.Decode(words)), nil
+	case bytes.HasPrefix(blob, []byte{0xef, 0xbb, 0xbf}):
+		return string(blob[3:]), nil
+	default:
+		return string(blob), nil
+	}
+}
+
+func stripInfComment(line string) string {
+	quoted := false
+	for i, c := range line {
+		switch c {
+		case '"':
+			quoted = !quoted
+		case ';':
+			if !quoted {
+				return line[:i]
+			}
+		}
+	}
+	return line
+}
+
+func expandInfStrings(v string, strs map[string]string) string {
+	var out strings.Builder
+	for {
+		start := strings.Index(v, "%")
+		if start < 0 {
+			break
+		}
+		end := strings.Index(v[start+1:], "%")
+		if end < 0 {
+			break
+		}
+		token := v[start+1 : start+1+end]
+		out.WriteString(v[:start])
+		if value, ok := strs[strings.ToLower(token)]; ok {
+			out.WriteString(value)
+		} else if token == "" {
+			out.WriteString("%")
+		} else {
+			out.WriteString(v[start : start+2+end])
+		}
+		v = v[start+2+end:]
+	}
+	out.WriteString(v)
+	return out.String()
+}
@@ -0,0 +1,128 @@
+package ossign
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/ossign/ossign/pkg/sigerr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testInf = `; sample driver
+[Version]
+Signature   = "$WINDOWS NT$"
+CatalogFile = %DriverName%.cat ; the catalog
+
+[SourceDisksNames]
+1 = %DiskName%,,,\amd64
+
+[SourceDisksFiles]
+%DriverName%.sys = 1
+firmware.bin     = 1,\fw
+
+[Manufacturer]
+%Mfg% = Models, NTamd64.10.0...16299, \
+	NTx86.6.1
+
+[Strings]
+DriverName = "sample"
+DiskName   = "Sample Disk"
+Mfg        = "OSSign"
+`
+
+// Encode text as UTF-16LE with a byte order mark, as written by most driver
+// tooling
+func utf16Inf(text string) []byte {
+	blob := []byte{0xff, 0xfe}
+	for _, w := range utf16.Encode([]rune(text)) {
+		blob = binary.LittleEndian.AppendUint16(blob, w)
+	}
+	return blob
+}
+
+func TestParseInf(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "amd64", "fw"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "amd64", "sample.sys"), fakePE(), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "amd64", "fw", "firmware.bin"), []byte("firmware"), 0644))
+
+	inf := filepath.Join(dir, "sample.inf")
+	require.NoError(t, os.WriteFile(inf, utf16Inf(testInf), 0644))
+
+	pkg, err := ParseInf(inf)
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "sample.cat")}, pkg.Catalogs)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "amd64", "sample.sys"),
+		filepath.Join(dir, "amd64", "fw", "firmware.bin"),
+	}, pkg.Files)
+	assert.Equal(t, []string{filepath.Join(dir, "amd64", "sample.sys")}, pkg.Binaries)
+	assert.Equal(t, "2:10.0,2:6.1", pkg.OSAttr)
+
+	require.NoError(t, os.Remove(filepath.Join(dir, "amd64", "fw", "firmware.bin")))
+	_, err = ParseInf(inf)
+	assert.True(t, errors.As(err, &sigerr.FormatError{}))
+}
+
+const testMultiArchInf = `[Version]
+Signature   = "$WINDOWS NT$"
+CatalogFile = sample.cat
+
+[SourceDisksNames]
+1 = "Common",,,\common
+
+[SourceDisksNames.amd64]
+1 = "Disk",,,\amd64
+
+[SourceDisksNames.arm64]
+1 = "Disk",,,\arm64
+
+[SourceDisksFiles]
+readme.txt = 1
+
+[SourceDisksFiles.amd64]
+sample.sys = 1
+
+[SourceDisksFiles.arm64]
+sample.sys = 1
+`
+
+func TestParseInfArchitectures(t *testing.T) {
+	dir := t.TempDir()
+	for _, sub := range []string{"common", "amd64", "arm64"} {
+		require.NoError(t, os.Mkdir(filepath.Join(dir, sub), 0755))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "common", "readme.txt"), []byte("readme"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "amd64", "sample.sys"), fakePE(), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "arm64", "sample.sys"), fakePE(), 0644))
+
+	inf := filepath.Join(dir, "sample.inf")
+	require.NoError(t, os.WriteFile(inf, []byte(testMultiArchInf), 0644))
+
+	// every architecture uses its own disk, whatever order the sections come in
+	for i := 0; i < 10; i++ {
+		pkg, err := ParseInf(inf)
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			filepath.Join(dir, "common", "readme.txt"),
+			filepath.Join(dir, "amd64", "sample.sys"),
+			filepath.Join(dir, "arm64", "sample.sys"),
+		}, pkg.Files)
+	}
+
+	// without a decorated section, the undecorated one is used
+	blob := strings.Replace(testMultiArchInf, "[SourceDisksNames.arm64]\n1 = \"Disk\",,,\\arm64\n", "", 1)
+	require.NoError(t, os.WriteFile(inf, []byte(blob), 0644))
+	_, err := ParseInf(inf)
+	assert.True(t, errors.As(err, &sigerr.FormatError{}), "common/sample.sys does not exist")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "common", "sample.sys"), fakePE(), 0644))
+	pkg, err := ParseInf(inf)
+	require.NoError(t, err)
+	assert.Contains(t, pkg.Files, filepath.Join(dir, "common", "sample.sys"))
+}
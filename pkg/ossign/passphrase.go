@@ -0,0 +1,104 @@
+package ossign
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/ossign/ossign/pkg/sigerr"
+	"github.com/sassoftware/relic/v8/lib/passprompt"
+	"golang.org/x/term"
+)
+
+// How often the user is asked again after typing a wrong passphrase
+const maxPassphrasePrompts = 3
+
+// Read a secret from the first source that is set: the value itself, an
+// environment variable, a file or the output of a command. The last return
+// value is false if no source is configured.
+func readSecret(value, env, file, command, what string) (string, bool, error) {
+	switch {
+	case value != "":
+		return value, true, nil
+	case env != "":
+		secret, ok := os.LookupEnv(env)
+		if !ok {
+			return "", true, sigerr.ConfigError{Err: fmt.Errorf("%s environment variable %s is not set", what, env)}
+		}
+		return secret, true, nil
+	case file != "":
+		secret, err := os.ReadFile(file)
+		if err != nil {
+			return "", true, sigerr.ConfigError{Err: fmt.Errorf("reading %s file: %w", what, err)}
+		}
+		return strings.TrimRight(string(secret), "\r\n"), true, nil
+	case command != "":
+		secret, err := runSecretCommand(command)
+		if err != nil {
+			return "", true, sigerr.ConfigError{Err: fmt.Errorf("running %s command: %w", what, err)}
+		}
+		return secret, true, nil
+	}
+	return "", false, nil
+}
+
+// Run a command through the shell and return the first line of its output
+func runSecretCommand(command string) (string, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+	}
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", err
+	}
+	secret, _, _ := strings.Cut(stdout.String(), "\n")
+	return strings.TrimRight(secret, "\r"), nil
+}
+
+func (c CertConfig) hasPassphrase() bool {
+	return c.Passphrase != "" || c.PassphraseEnv != "" || c.PassphraseFile != "" || c.PassphraseCommand != ""
+}
+
+// Return the configured passphrase, or ask for it on the terminal showing the
+// given prompt if none is configured and stdin is a terminal
+func (c CertConfig) GetPasswd(prompt string) (string, error) {
+	secret, ok, err := readSecret(c.Passphrase, c.PassphraseEnv, c.PassphraseFile, c.PassphraseCommand, "passphrase")
+	if ok || err != nil {
+		return secret, err
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return "", sigerr.ConfigError{Err: errors.New("the private key is encrypted, but no passphrase is configured")}
+	}
+	return passprompt.PasswordPrompt{}.GetPasswd(prompt)
+}
+
+// certloader asks again as long as the passphrase is wrong, which must not
+// loop forever when the passphrase comes from the configuration
+type passphraseGetter struct {
+	conf  CertConfig
+	tries int
+}
+
+func (c CertConfig) passphraseGetter() *passphraseGetter {
+	return &passphraseGetter{conf: c}
+}
+
+func (g *passphraseGetter) GetPasswd(prompt string) (string, error) {
+	g.tries++
+	if g.tries > 1 && g.conf.hasPassphrase() || g.tries > maxPassphrasePrompts {
+		return "", sigerr.ConfigError{Err: errors.New("incorrect passphrase")}
+	}
+	if g.tries > 1 {
+		prompt = "Incorrect passphrase. " + prompt
+	}
+	return g.conf.GetPasswd(prompt)
+}
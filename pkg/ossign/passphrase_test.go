@@ -0,0 +1,68 @@
+package ossign
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/ossign/ossign/pkg/sigerr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadSecret(t *testing.T) {
+	t.Setenv("OSSIGN_TEST_SECRET", "from-env")
+	path := filepath.Join(t.TempDir(), "secret")
+	require.NoError(t, os.WriteFile(path, []byte("from-file\n"), 0600))
+
+	secret, ok, err := readSecret("", "OSSIGN_TEST_SECRET", path, "", "passphrase")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "from-env", secret)
+
+	secret, _, err = readSecret("", "", path, "", "passphrase")
+	require.NoError(t, err)
+	assert.Equal(t, "from-file", secret)
+
+	if runtime.GOOS != "windows" {
+		secret, _, err = readSecret("", "", "", "echo from-command", "passphrase")
+		require.NoError(t, err)
+		assert.Equal(t, "from-command", secret)
+	}
+
+	_, ok, err = readSecret("", "", "", "", "passphrase")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	_, _, err = readSecret("", "OSSIGN_TEST_UNSET", "", "", "passphrase")
+	assert.True(t, errors.As(err, &sigerr.ConfigError{}))
+}
+
+func TestEncryptedPrivateKey(t *testing.T) {
+	key, leaf := testCertificate(t)
+	der, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	block, err := x509.EncryptPEMBlock(rand.Reader, "EC PRIVATE KEY", der, []byte("secret"), x509.PEMCipherAES256) //nolint:staticcheck
+	require.NoError(t, err)
+
+	conf := CertConfig{
+		Certificate:   string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leaf.Raw})),
+		PrivateKey:    string(pem.EncodeToMemory(block)),
+		PassphraseEnv: "OSSIGN_TEST_PASSPHRASE",
+	}
+
+	t.Setenv("OSSIGN_TEST_PASSPHRASE", "secret")
+	cert, err := conf.Load()
+	require.NoError(t, err)
+	assert.True(t, key.Equal(cert.PrivateKey))
+
+	// A wrong passphrase from the configuration fails instead of asking again
+	t.Setenv("OSSIGN_TEST_PASSPHRASE", "wrong")
+	_, err = conf.Load()
+	assert.True(t, errors.As(err, &sigerr.ConfigError{}))
+}
@@ -0,0 +1,178 @@
+package ossign
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/ossign/ossign/pkg/sigerr"
+)
+
+const (
+	PolicyEnforce = "enforce"
+	PolicyWarn    = "warn"
+	PolicyOff     = "off"
+
+	defaultExpiryWarningDays = 30
+	defaultMinRsaBits        = 2048
+)
+
+// Checks of the signing certificate before anything is signed
+type CertificatePolicy struct {
+	// enforce (default) fails on violations, warn only logs them and off skips the checks
+	Mode string `json:"mode,omitempty" yaml:"mode,omitempty" mapstructure:"mode"`
+	// Warn this many days before the certificate expires, 30 by default
+	ExpiryWarningDays int `json:"expiryWarningDays,omitempty" yaml:"expiryWarningDays,omitempty" mapstructure:"expiryWarningDays"`
+	MinRsaBits        int `json:"minRsaBits,omitempty" yaml:"minRsaBits,omitempty" mapstructure:"minRsaBits"`
+	// SHA-256 fingerprint the certificate must have, in hex with or without colons
+	Thumbprint string `json:"thumbprint,omitempty" yaml:"thumbprint,omitempty" mapstructure:"thumbprint"`
+}
+
+// Check the certificate against the policy. The extended key usage is only
+// checked for an explicit format, as auto detected ones are checked per file.
+func (p CertificatePolicy) check(cert *x509.Certificate, format SignatureType, now time.Time) error {
+	if p.Mode == PolicyOff {
+		return nil
+	}
+
+	var problems []string
+	if now.Before(cert.NotBefore) {
+		problems = append(problems, fmt.Sprintf("certificate is not valid before %s", cert.NotBefore.Format(time.RFC3339)))
+	}
+	if now.After(cert.NotAfter) {
+		problems = append(problems, fmt.Sprintf("certificate expired on %s", cert.NotAfter.Format(time.RFC3339)))
+	} else if warning := p.expiryWarning(cert, now); warning != "" {
+		log.Print(warning)
+	}
+
+	if cert.KeyUsage != 0 && cert.KeyUsage&x509.KeyUsageDigitalSignature == 0 {
+		problems = append(problems, "certificate key usage does not allow digital signatures")
+	}
+	if problem := p.checkKey(cert); problem != "" {
+		problems = append(problems, problem)
+	}
+	if p.Thumbprint != "" {
+		sum := sha256.Sum256(cert.Raw)
+		want := strings.ToLower(strings.NewReplacer(":", "", " ", "").Replace(p.Thumbprint))
+		if hex.EncodeToString(sum[:]) != want {
+			problems = append(problems, fmt.Sprintf("certificate thumbprint %X does not match the pinned one", sum))
+		}
+	}
+	if format != "" && format != AutoSignature {
+		if problem := checkExtKeyUsage(cert, format); problem != "" {
+			problems = append(problems, problem)
+		}
+	}
+
+	return p.report(problems)
+}
+
+// Warn about a certificate that expires soon. Short-lived certificates, such
+// as the 3 day ones of Artifact Signing, would always warn and are skipped.
+func (p CertificatePolicy) expiryWarning(cert *x509.Certificate, now time.Time) string {
+	days := p.ExpiryWarningDays
+	if days == 0 {
+		days = defaultExpiryWarningDays
+	}
+	threshold := time.Duration(days) * 24 * time.Hour
+	left := cert.NotAfter.Sub(now)
+	if left >= threshold || cert.NotAfter.Sub(cert.NotBefore) < threshold {
+		return ""
+	}
+	return fmt.Sprintf("Certificate %s expires in %d days, on %s", cert.Subject, int(left.Hours()/24), cert.NotAfter.Format(time.RFC3339))
+}
+
+// Check that the certificate may sign files of the given format
+func (p CertificatePolicy) checkUsage(cert *x509.Certificate, format SignatureType) error {
+	if p.Mode == PolicyOff {
+		return nil
+	}
+	var problems []string
+	if problem := checkExtKeyUsage(cert, format); problem != "" {
+		problems = append(problems, problem)
+	}
+	return p.report(problems)
+}
+
+func (p CertificatePolicy) report(problems []string) error {
+	switch p.Mode {
+	case PolicyEnforce, "":
+		if len(problems) > 0 {
+			return sigerr.ConfigError{Err: errors.New(strings.Join(problems, "; "))}
+		}
+	case PolicyWarn:
+		for _, problem := range problems {
+			log.Printf("Certificate policy: %s", problem)
+		}
+	default:
+		return sigerr.ConfigError{Err: fmt.Errorf("unknown certificate policy mode %q", p.Mode)}
+	}
+	return nil
+}
+
+func (p CertificatePolicy) checkKey(cert *x509.Certificate) string {
+	switch key := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		min := p.MinRsaBits
+		if min == 0 {
+			min = defaultMinRsaBits
+		}
+		if key.N.BitLen() < min {
+			return fmt.Sprintf("RSA key has %d bits, at least %d are required", key.N.BitLen(), min)
+		}
+	case *ecdsa.PublicKey:
+		switch key.Curve {
+		case elliptic.P256(), elliptic.P384(), elliptic.P521():
+		default:
+			return fmt.Sprintf("ECDSA curve %s is not supported", key.Curve.Params().Name)
+		}
+	default:
+		return fmt.Sprintf("unsupported public key type %T", cert.PublicKey)
+	}
+	return ""
+}
+
+// Extended key usages accepted for signing each format. Only Code Signing is
+// checked: the Apple specific usages of Developer ID and Mac App Store
+// certificates are not, as Apple certificates carry Code Signing too. Formats
+// without an entry accept any certificate.
+var formatExtKeyUsages = map[SignatureType][]x509.ExtKeyUsage{
+	PowershellSignature:   {x509.ExtKeyUsageCodeSigning},
+	PecoffSignature:       {x509.ExtKeyUsageCodeSigning},
+	AuthenticodeSignature: {x509.ExtKeyUsageCodeSigning},
+	MsiSignature:          {x509.ExtKeyUsageCodeSigning},
+	AppxSignature:         {x509.ExtKeyUsageCodeSigning},
+	AppmanifestSignature:  {x509.ExtKeyUsageCodeSigning},
+	CabSignature:          {x509.ExtKeyUsageCodeSigning},
+	CatalogSignature:      {x509.ExtKeyUsageCodeSigning},
+	DmgSignature:          {x509.ExtKeyUsageCodeSigning},
+	MachosSignature:       {x509.ExtKeyUsageCodeSigning},
+	JarSignature:          {x509.ExtKeyUsageCodeSigning},
+}
+
+func checkExtKeyUsage(cert *x509.Certificate, format SignatureType) string {
+	accepted, ok := formatExtKeyUsages[format]
+	// a certificate without extended key usages is not restricted
+	if !ok || len(cert.ExtKeyUsage) == 0 && len(cert.UnknownExtKeyUsage) == 0 {
+		return ""
+	}
+	for _, usage := range cert.ExtKeyUsage {
+		if usage == x509.ExtKeyUsageAny {
+			return ""
+		}
+		for _, want := range accepted {
+			if usage == want {
+				return ""
+			}
+		}
+	}
+	return fmt.Sprintf("certificate lacks the Code Signing extended key usage needed for %s files", format)
+}
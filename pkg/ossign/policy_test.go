@@ -0,0 +1,81 @@
+package ossign
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ossign/ossign/pkg/sigerr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCertificatePolicy(t *testing.T) {
+	_, cert := testCertificate(t)
+	now := time.Now()
+	policy := CertificatePolicy{}
+
+	assert.NoError(t, policy.check(cert, PecoffSignature, now))
+	assert.NoError(t, policy.check(cert, AutoSignature, now))
+
+	err := policy.check(cert, PecoffSignature, now.Add(2*time.Hour))
+	assert.True(t, errors.As(err, &sigerr.ConfigError{}))
+	assert.ErrorContains(t, err, "certificate expired")
+	assert.NoError(t, CertificatePolicy{Mode: PolicyWarn}.check(cert, PecoffSignature, now.Add(2*time.Hour)))
+	assert.NoError(t, CertificatePolicy{Mode: PolicyOff}.check(cert, PecoffSignature, now.Add(2*time.Hour)))
+
+	sum := sha256.Sum256(cert.Raw)
+	assert.NoError(t, CertificatePolicy{Thumbprint: fmt.Sprintf("% X", sum)}.check(cert, PecoffSignature, now))
+	assert.ErrorContains(t, CertificatePolicy{Thumbprint: "00:11"}.check(cert, PecoffSignature, now), "thumbprint")
+
+	assert.ErrorContains(t, CertificatePolicy{Mode: "strict"}.check(cert, PecoffSignature, now), "unknown certificate policy mode")
+}
+
+func TestCertificatePolicyExpiryWarning(t *testing.T) {
+	now := time.Now()
+	cert := &x509.Certificate{
+		Subject:   pkix.Name{CommonName: "signer"},
+		NotBefore: now.Add(-365 * 24 * time.Hour),
+		NotAfter:  now.Add(10 * 24 * time.Hour),
+	}
+	assert.Contains(t, CertificatePolicy{}.expiryWarning(cert, now), "expires in 10 days")
+	assert.Empty(t, CertificatePolicy{ExpiryWarningDays: 5}.expiryWarning(cert, now))
+
+	// a 3 day certificate is always close to expiry
+	cert.NotBefore = now.Add(-24 * time.Hour)
+	cert.NotAfter = now.Add(48 * time.Hour)
+	assert.Empty(t, CertificatePolicy{}.expiryWarning(cert, now))
+	assert.Contains(t, CertificatePolicy{ExpiryWarningDays: 3}.expiryWarning(cert, now), "expires in 2 days")
+}
+
+func TestCertificatePolicyUsage(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "tls server"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	err = CertificatePolicy{}.check(cert, MsiSignature, time.Now())
+	assert.ErrorContains(t, err, "key usage does not allow digital signatures")
+	assert.ErrorContains(t, err, "RSA key has 1024 bits")
+	assert.ErrorContains(t, err, "Code Signing")
+
+	assert.NoError(t, CertificatePolicy{MinRsaBits: 1024}.checkUsage(cert, "unknown"))
+	assert.ErrorContains(t, CertificatePolicy{}.checkUsage(cert, DmgSignature), "Code Signing")
+}
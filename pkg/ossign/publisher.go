@@ -0,0 +1,50 @@
+package ossign
+
+import (
+	"io"
+	"strconv"
+
+	"github.com/ossign/ossign/pkg/signers"
+	"github.com/sassoftware/relic/v8/lib/authenticode"
+)
+
+// Parameter names for the publisher information embedded in Authenticode
+// signatures
+const (
+	ParamDescription                = "description"
+	ParamURL                        = "url"
+	ParamDescriptionFromVersionInfo = "descriptionFromVersionInfo"
+)
+
+// Build the publisher description and URL shown in the Windows "more info"
+// dialog. When no description is given and descriptionFromVersionInfo is set,
+// it is taken from the VERSIONINFO resource of pe, if pe is not nil.
+func (o *SignOptions) OpusParams(pe io.ReaderAt) *authenticode.OpusParams {
+	params := &authenticode.OpusParams{
+		Description: o.GetParamDefault(ParamDescription, ""),
+		URL:         o.GetParamDefault(ParamURL, signers.DefaultURL),
+	}
+
+	if params.Description == "" && pe != nil {
+		if fromFile, _ := strconv.ParseBool(o.GetParamDefault(ParamDescriptionFromVersionInfo, "")); fromFile {
+			params.Description = versionInfoDescription(pe)
+		}
+	}
+
+	if params.Description == "" {
+		params.Description = signers.DefaultDescription
+	}
+
+	return params
+}
+
+func versionInfoDescription(pe io.ReaderAt) string {
+	info, err := ReadVersionInfo(pe)
+	if err != nil {
+		return ""
+	}
+	if desc := info["FileDescription"]; desc != "" {
+		return desc
+	}
+	return info["ProductName"]
+}
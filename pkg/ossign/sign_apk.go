@@ -0,0 +1,47 @@
+package ossign
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"fmt"
+	"strconv"
+
+	"github.com/ossign/ossign/pkg/sigerr"
+	"github.com/ossign/ossign/pkg/signers"
+	"github.com/ossign/ossign/pkg/transformers"
+	"github.com/sassoftware/relic/v8/lib/certloader"
+	rvfs "github.com/sassoftware/relic/v8/lib/vfs"
+)
+
+// Parameter name for adding a v1 (JAR) signature to APKs, which Android
+// versions before 7.0 need
+const ParamApkV1 = "apkV1"
+
+func SignApk(input *rvfs.File, signerCert *certloader.Certificate, opts *SignOptions, outfile *rvfs.File, ctx context.Context) error {
+	hash, err := opts.HashFunc()
+	if err != nil {
+		return err
+	}
+	if hash != crypto.SHA256 && hash != crypto.SHA512 {
+		return sigerr.ConfigError{Err: fmt.Errorf("APK signatures support sha256 and sha512, not %s", hash)}
+	}
+	v1, _ := strconv.ParseBool(opts.GetParamDefault(ParamApkV1, ""))
+
+	transformer, err := transformers.NewZipTransformer(input)
+	if err != nil {
+		return sigerr.FormatError{Err: fmt.Errorf("Error creating ZIP transformer: %w", err)}
+	}
+	transformReader, err := transformer.GetReader()
+	if err != nil {
+		return sigerr.FormatError{Err: fmt.Errorf("Error getting transformer reader: %w", err)}
+	}
+
+	alias := opts.GetParamDefault(ParamKeyAlias, DefaultKeyAlias)
+	signed, err := signers.SignApk(transformReader, signerCert, alias, v1, hash, ctx)
+	if err != nil {
+		return fmt.Errorf("Error signing file: %w", err)
+	}
+
+	return transformer.Apply(outfile, "application/x-binary-patch", bytes.NewReader(signed))
+}
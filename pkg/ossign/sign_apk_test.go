@@ -0,0 +1,123 @@
+package ossign
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"testing"
+
+	"github.com/ossign/ossign/pkg/sigerr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Build an APK with an uncompressed native library padded to a page boundary,
+// as zipalign does
+func testApk(t *testing.T) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	lib := bytes.Repeat([]byte{0x7f, 'E', 'L', 'F'}, 1024)
+	name := "lib/arm64-v8a/libnative.so"
+	pad := (4096 - (30+len(name))%4096) % 4096
+	w, err := zw.CreateRaw(&zip.FileHeader{
+		Name:               name,
+		Method:             zip.Store,
+		CRC32:              crc32.ChecksumIEEE(lib),
+		CompressedSize64:   uint64(len(lib)),
+		UncompressedSize64: uint64(len(lib)),
+		Extra:              make([]byte, pad),
+	})
+	require.NoError(t, err)
+	_, err = w.Write(lib)
+	require.NoError(t, err)
+
+	w, err = zw.Create("AndroidManifest.xml")
+	require.NoError(t, err)
+	_, err = w.Write([]byte("<manifest package=\"org.ossign.test\"/>"))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+func libOffset(t *testing.T, blob []byte) int64 {
+	zr, err := zip.NewReader(bytes.NewReader(blob), int64(len(blob)))
+	require.NoError(t, err)
+	for _, f := range zr.File {
+		if f.Name == "lib/arm64-v8a/libnative.so" {
+			offset, err := f.DataOffset()
+			require.NoError(t, err)
+			return offset
+		}
+	}
+	t.Fatal("library missing")
+	return 0
+}
+
+func TestSignApk(t *testing.T) {
+	s := testSigner(t)
+	leaf := s.Cert.Leaf
+	sign := func(blob []byte, params map[string]string) []byte {
+		r, err := s.Sign(context.Background(), bytes.NewReader(blob), int64(len(blob)), ApkSignature, &SignOptions{Filename: "app.apk", Params: params})
+		require.NoError(t, err)
+		signed, err := io.ReadAll(r)
+		require.NoError(t, err)
+		return signed
+	}
+
+	unsigned := testApk(t)
+	require.Zero(t, libOffset(t, unsigned)%4096)
+
+	signed := sign(unsigned, map[string]string{ParamApkV1: "true"})
+	sigs, err := Verify(bytes.NewReader(signed), int64(len(signed)), AutoSignature, "app.apk")
+	require.NoError(t, err)
+	assert.Len(t, sigs, 3)
+	for _, sig := range sigs {
+		assert.True(t, sig.Certificate.Equal(leaf))
+		assert.Equal(t, ApkSignature, sig.Format)
+	}
+	assert.Equal(t, libOffset(t, unsigned), libOffset(t, signed))
+
+	// signing again replaces all schemes
+	resigned := sign(signed, nil)
+	sigs, err = Verify(bytes.NewReader(resigned), int64(len(resigned)), ApkSignature, "app.apk")
+	require.NoError(t, err)
+	assert.Len(t, sigs, 2)
+	assert.Equal(t, libOffset(t, unsigned), libOffset(t, resigned))
+
+	tampered := bytes.Clone(resigned)
+	tampered[libOffset(t, tampered)] ^= 0xff
+	_, err = Verify(bytes.NewReader(tampered), int64(len(tampered)), ApkSignature, "app.apk")
+	assert.ErrorContains(t, err, "digest mismatch")
+}
+
+func TestVerifyApkTruncatedSigningBlock(t *testing.T) {
+	s := testSigner(t)
+	unsigned := testApk(t)
+	r, err := s.Sign(context.Background(), bytes.NewReader(unsigned), int64(len(unsigned)), ApkSignature, &SignOptions{Filename: "app.apk"})
+	require.NoError(t, err)
+	signed, err := io.ReadAll(r)
+	require.NoError(t, err)
+
+	// find the v2 pair in the signing block, which ends just before the
+	// central directory with its size and magic
+	magic := bytes.Index(signed, []byte("APK Sig Block 42"))
+	require.Positive(t, magic)
+	sigLoc := magic + 16 - int(binary.LittleEndian.Uint64(signed[magic-8:])) - 8
+	pair := sigLoc + 8
+	for binary.LittleEndian.Uint32(signed[pair+8:]) != 0x7109871a {
+		pair += 8 + int(binary.LittleEndian.Uint64(signed[pair:]))
+		require.Less(t, pair, magic-8, "no v2 signature")
+	}
+
+	// the list of signers claims a few bytes more than the pair holds
+	value := pair + 12
+	tampered := bytes.Clone(signed)
+	binary.LittleEndian.PutUint32(tampered[value:], binary.LittleEndian.Uint32(signed[value:])+2)
+	_, err = Verify(bytes.NewReader(tampered), int64(len(tampered)), ApkSignature, "app.apk")
+	assert.True(t, errors.As(err, &sigerr.VerifyError{}), err)
+	assert.ErrorContains(t, err, "parsing v2 signature block")
+}
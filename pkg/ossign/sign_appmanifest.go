@@ -1,4 +1,4 @@
-package main
+package ossign
 
 import (
 	"context"
@@ -9,15 +9,20 @@ import (
 	rvfs "github.com/sassoftware/relic/v8/lib/vfs"
 )
 
-func SignAppmanifest(input *rvfs.File, signerCert *certloader.Certificate, filename string, outfile *rvfs.File, ctx context.Context) error {
-	signed, err := signers.SignAppmanifest(input, signerCert, filename, ctx)
+func SignAppmanifest(input *rvfs.File, signerCert *certloader.Certificate, opts *SignOptions, outfile *rvfs.File, ctx context.Context) error {
+	hash, err := opts.HashFunc()
 	if err != nil {
-		return fmt.Errorf("Error signing file: %v", err)
+		return err
+	}
+
+	signed, err := signers.SignAppmanifest(input, signerCert, opts.Filename, hash, ctx)
+	if err != nil {
+		return fmt.Errorf("Error signing file: %w", err)
 	}
 
 	_, err = outfile.Write(signed)
 	if err != nil {
-		return fmt.Errorf("Error writing signed data to output file: %v", err)
+		return fmt.Errorf("Error writing signed data to output file: %w", err)
 	}
 
 	return nil
@@ -1,29 +1,35 @@
-package main
+package ossign
 
 import (
 	"bytes"
 	"context"
 	"fmt"
 
+	"github.com/ossign/ossign/pkg/sigerr"
 	"github.com/ossign/ossign/pkg/signers"
 	"github.com/ossign/ossign/pkg/transformers"
 	"github.com/sassoftware/relic/v8/lib/certloader"
 	rvfs "github.com/sassoftware/relic/v8/lib/vfs"
 )
 
-func SignAppx(input *rvfs.File, signerCert *certloader.Certificate, filename string, outfile *rvfs.File, ctx context.Context) error {
+func SignAppx(input *rvfs.File, signerCert *certloader.Certificate, opts *SignOptions, outfile *rvfs.File, ctx context.Context) error {
+	hash, err := opts.HashFunc()
+	if err != nil {
+		return err
+	}
+
 	transformer, err := transformers.NewZipTransformer(input)
 	if err != nil {
-		return fmt.Errorf("Error creating ZIP transformer: %v", err)
+		return sigerr.FormatError{Err: fmt.Errorf("Error creating ZIP transformer: %w", err)}
 	}
 	transformReader, err := transformer.GetReader()
 	if err != nil {
-		return fmt.Errorf("Error getting transformer reader: %v", err)
+		return sigerr.FormatError{Err: fmt.Errorf("Error getting transformer reader: %w", err)}
 	}
 
-	signed, err := signers.SignAppx(transformReader, signerCert, filename, ctx)
+	signed, err := signers.SignAppx(transformReader, signerCert, opts.Filename, hash, opts.OpusParams(nil), ctx)
 	if err != nil {
-		return fmt.Errorf("Error signing file: %v", err)
+		return fmt.Errorf("Error signing file: %w", err)
 	}
 
 	return transformer.Apply(outfile, "application/x-binary-patch", bytes.NewReader(signed))
@@ -0,0 +1,33 @@
+package ossign
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/ossign/ossign/pkg/sigerr"
+	"github.com/ossign/ossign/pkg/signers"
+	"github.com/ossign/ossign/pkg/transformers"
+	"github.com/sassoftware/relic/v8/lib/certloader"
+	rvfs "github.com/sassoftware/relic/v8/lib/vfs"
+)
+
+func SignCab(input *rvfs.File, signerCert *certloader.Certificate, opts *SignOptions, outfile *rvfs.File, ctx context.Context) error {
+	hash, err := opts.HashFunc()
+	if err != nil {
+		return err
+	}
+
+	transformer := transformers.NewDefaultTransformer(input)
+	transformReader, err := transformer.GetReader()
+	if err != nil {
+		return sigerr.FormatError{Err: fmt.Errorf("Error getting transformer reader: %w", err)}
+	}
+
+	signed, err := signers.SignCab(transformReader, signerCert, opts.Filename, hash, opts.OpusParams(nil), ctx)
+	if err != nil {
+		return fmt.Errorf("Error signing file: %w", err)
+	}
+
+	return transformer.Apply(outfile, "application/x-binary-patch", bytes.NewReader(signed))
+}
@@ -0,0 +1,64 @@
+package ossign
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"testing"
+
+	"github.com/ossign/ossign/pkg/authenticode"
+	"github.com/sassoftware/relic/v8/lib/cabfile"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Build an uncompressed cabinet holding a single file
+func testCabinet(name string, content []byte) []byte {
+	const headerSize, folderSize, fileSize, dataSize = 36, 8, 16, 8
+	offsetFiles := headerSize + folderSize
+	offsetData := offsetFiles + fileSize + len(name) + 1
+	total := offsetData + dataSize + len(content)
+
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, cabfile.Header{
+		Magic:       cabfile.Magic,
+		TotalSize:   uint32(total),
+		OffsetFiles: uint32(offsetFiles),
+		Version:     0x0103,
+		NumFolders:  1,
+		NumFiles:    1,
+	})
+	binary.Write(&buf, binary.LittleEndian, cabfile.FolderHeader{Offset: uint32(offsetData), NumData: 1})
+	binary.Write(&buf, binary.LittleEndian, struct {
+		Size, FolderOffset            uint32
+		Folder, Date, Time, Attribute uint16
+	}{Size: uint32(len(content))})
+	buf.WriteString(name + "\x00")
+	binary.Write(&buf, binary.LittleEndian, struct {
+		Checksum           uint32
+		Size, Uncompressed uint16
+	}{Size: uint16(len(content)), Uncompressed: uint16(len(content))})
+	buf.Write(content)
+	return buf.Bytes()
+}
+
+func TestSignCab(t *testing.T) {
+	s := testSigner(t)
+	leaf := s.Cert.Leaf
+
+	cab := testCabinet("payload.txt", []byte("hello cabinet"))
+	signed := cab
+	// signing again replaces the signature in its reserve
+	for i := 0; i < 2; i++ {
+		r, err := s.Sign(context.Background(), bytes.NewReader(signed), int64(len(signed)), CabSignature, &SignOptions{Filename: "payload.cab"})
+		require.NoError(t, err)
+		signed, err = io.ReadAll(r)
+		require.NoError(t, err)
+
+		sig, err := authenticode.VerifyCab(bytes.NewReader(signed), false)
+		require.NoError(t, err)
+		assert.True(t, sig.Certificate.Equal(leaf))
+	}
+	assert.Greater(t, len(signed), len(cab))
+}
@@ -1,11 +1,11 @@
-package main
+package ossign
 
 import (
 	"bytes"
 	"context"
-	"crypto"
 	"fmt"
 
+	"github.com/ossign/ossign/pkg/sigerr"
 	"github.com/ossign/ossign/pkg/transformers"
 	"github.com/sassoftware/relic/v8/lib/certloader"
 	"github.com/sassoftware/relic/v8/lib/fruit/dmg"
@@ -17,29 +17,34 @@ const (
 	dmgName  = "contents.dmg"
 )
 
-func SignDmg(input *rvfs.File, signerCert *certloader.Certificate, filename string, outfile *rvfs.File, ctx context.Context) error {
+func SignDmg(input *rvfs.File, signerCert *certloader.Certificate, opts *SignOptions, outfile *rvfs.File, ctx context.Context) error {
+	hash, err := opts.HashFunc()
+	if err != nil {
+		return err
+	}
+
 	args, payload, err := transformers.DmgExtractFiles(input)
 	if err != nil {
-		return fmt.Errorf("Error extracting DMG files: %v", err)
+		return sigerr.FormatError{Err: fmt.Errorf("Error extracting DMG files: %w", err)}
 	}
 
 	requirements, ok := args["requirements"]
 	if !ok {
-		if reqs := GlobalConfig.GetParamDefault("requirements", ""); reqs != "" {
+		if reqs := opts.GetParamDefault("requirements", ""); reqs != "" {
 			requirements = []byte(reqs)
 		} else {
-			return fmt.Errorf("No requirements file found in DMG")
+			return sigerr.FormatError{Err: fmt.Errorf("No requirements file found in DMG")}
 		}
 	}
 
 	transformer, err := transformers.NewDmgTransformer(input, requirements)
 	if err != nil {
-		return fmt.Errorf("Error creating MSI transformer: %v", err)
+		return sigerr.FormatError{Err: fmt.Errorf("Error creating DMG transformer: %w", err)}
 	}
 
 	params := &dmg.SignatureParams{
-		HashFunc:        crypto.SHA256,
-		SigningIdentity: GlobalConfig.GetParamDefault("signingIdentity", "Developer ID Application: Unknown"),
+		HashFunc:        hash,
+		SigningIdentity: opts.GetParamDefault("signingIdentity", "Developer ID Application: Unknown"),
 	}
 
 	udifBytes := args[udifName]
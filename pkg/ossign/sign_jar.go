@@ -0,0 +1,43 @@
+package ossign
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/ossign/ossign/pkg/sigerr"
+	"github.com/ossign/ossign/pkg/signers"
+	"github.com/ossign/ossign/pkg/transformers"
+	"github.com/sassoftware/relic/v8/lib/certloader"
+	rvfs "github.com/sassoftware/relic/v8/lib/vfs"
+)
+
+// Parameter name for the alias that the signature files in META-INF are named
+// after, e.g. OSSIGN.SF and OSSIGN.RSA
+const ParamKeyAlias = "keyAlias"
+
+const DefaultKeyAlias = "OSSIGN"
+
+func SignJar(input *rvfs.File, signerCert *certloader.Certificate, opts *SignOptions, outfile *rvfs.File, ctx context.Context) error {
+	hash, err := opts.HashFunc()
+	if err != nil {
+		return err
+	}
+
+	transformer, err := transformers.NewZipTransformer(input)
+	if err != nil {
+		return sigerr.FormatError{Err: fmt.Errorf("Error creating ZIP transformer: %w", err)}
+	}
+	transformReader, err := transformer.GetReader()
+	if err != nil {
+		return sigerr.FormatError{Err: fmt.Errorf("Error getting transformer reader: %w", err)}
+	}
+
+	alias := opts.GetParamDefault(ParamKeyAlias, DefaultKeyAlias)
+	signed, err := signers.SignJar(transformReader, signerCert, alias, hash, ctx)
+	if err != nil {
+		return fmt.Errorf("Error signing file: %w", err)
+	}
+
+	return transformer.Apply(outfile, "application/x-binary-patch", bytes.NewReader(signed))
+}
@@ -0,0 +1,60 @@
+package ossign
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignJar(t *testing.T) {
+	s := testSigner(t)
+	leaf := s.Cert.Leaf
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create("META-INF/MANIFEST.MF")
+	require.NoError(t, err)
+	_, err = w.Write([]byte("Manifest-Version: 1.0\r\nMain-Class: Main\r\n\r\n"))
+	require.NoError(t, err)
+	w, err = zw.Create("Main.class")
+	require.NoError(t, err)
+	_, err = w.Write(bytes.Repeat([]byte{0xca, 0xfe, 0xba, 0xbe}, 256))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	jar := buf.Bytes()
+
+	signed := jar
+	// signing again replaces the previous signature files
+	for i := 0; i < 2; i++ {
+		opts := &SignOptions{Filename: "app.jar", Params: map[string]string{ParamKeyAlias: "release"}}
+		r, err := s.Sign(context.Background(), bytes.NewReader(signed), int64(len(signed)), JarSignature, opts)
+		require.NoError(t, err)
+		signed, err = io.ReadAll(r)
+		require.NoError(t, err)
+
+		sigs, err := Verify(bytes.NewReader(signed), int64(len(signed)), AutoSignature, "app.jar")
+		require.NoError(t, err)
+		require.Len(t, sigs, 1)
+		assert.True(t, sigs[0].Certificate.Equal(leaf))
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(signed), int64(len(signed)))
+	require.NoError(t, err)
+	names := make(map[string]*zip.File)
+	for _, f := range zr.File {
+		names[f.Name] = f
+	}
+	assert.Contains(t, names, "META-INF/RELEASE.SF")
+	assert.Contains(t, names, "META-INF/RELEASE.EC")
+	// entries are copied without being compressed again
+	orig, err := zip.NewReader(bytes.NewReader(jar), int64(len(jar)))
+	require.NoError(t, err)
+	require.Contains(t, names, "Main.class")
+	assert.Equal(t, orig.File[1].CompressedSize64, names["Main.class"].CompressedSize64)
+	assert.Equal(t, orig.File[1].CRC32, names["Main.class"].CRC32)
+}
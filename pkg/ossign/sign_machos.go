@@ -1,11 +1,11 @@
-package main
+package ossign
 
 import (
 	"bytes"
 	"context"
-	"crypto"
 	"fmt"
 
+	"github.com/ossign/ossign/pkg/sigerr"
 	"github.com/ossign/ossign/pkg/transformers"
 	"github.com/sassoftware/relic/v8/lib/certloader"
 	"github.com/sassoftware/relic/v8/lib/fruit/csblob"
@@ -13,25 +13,30 @@ import (
 	rvfs "github.com/sassoftware/relic/v8/lib/vfs"
 )
 
-func SignMachos(input *rvfs.File, signerCert *certloader.Certificate, filename string, outfile *rvfs.File, ctx context.Context) error {
+func SignMachos(input *rvfs.File, signerCert *certloader.Certificate, opts *SignOptions, outfile *rvfs.File, ctx context.Context) error {
+	hash, err := opts.HashFunc()
+	if err != nil {
+		return err
+	}
+
 	transformer, err := transformers.NewMachosTransformer(input)
 	if err != nil {
-		return fmt.Errorf("Error creating MSI transformer: %v", err)
+		return sigerr.FormatError{Err: fmt.Errorf("Error creating Mach-O transformer: %w", err)}
 	}
 
 	transReader, err := transformer.GetReader()
 	if err != nil {
-		return fmt.Errorf("Error getting transformer reader: %v", err)
+		return sigerr.FormatError{Err: fmt.Errorf("Error getting transformer reader: %w", err)}
 	}
 
 	args, payload, err := transformers.DmgExtractFiles(transReader)
 	if err != nil {
-		return fmt.Errorf("Error extracting DMG files: %v", err)
+		return sigerr.FormatError{Err: fmt.Errorf("Error extracting DMG files: %w", err)}
 	}
 
 	params := &csblob.SignatureParams{
-		HashFunc:        crypto.SHA256,
-		SigningIdentity: GlobalConfig.GetParamDefault("signingIdentity", "Developer ID Application: Unknown"),
+		HashFunc:        hash,
+		SigningIdentity: opts.GetParamDefault("signingIdentity", "Developer ID Application: Unknown"),
 	}
 
 	if v := args["info-plist"]; v != nil {
@@ -0,0 +1,43 @@
+package ossign
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/ossign/ossign/pkg/authenticode"
+	"github.com/ossign/ossign/pkg/sigerr"
+	"github.com/ossign/ossign/pkg/signers"
+	"github.com/ossign/ossign/pkg/transformers"
+	"github.com/sassoftware/relic/v8/lib/certloader"
+	rvfs "github.com/sassoftware/relic/v8/lib/vfs"
+)
+
+func SignPecoff(input *rvfs.File, signerCert *certloader.Certificate, opts *SignOptions, outfile *rvfs.File, ctx context.Context) error {
+	hash, err := opts.HashFunc()
+	if err != nil {
+		return err
+	}
+
+	// keep an existing signature and nest ours inside it
+	var primary []byte
+	if appendSig, _ := strconv.ParseBool(opts.GetParamDefault(ParamAppend, "")); appendSig {
+		if primary, err = authenticode.ReadPESignature(input); err != nil {
+			return sigerr.FormatError{Err: fmt.Errorf("Error reading existing signature: %w", err)}
+		}
+	}
+
+	transformer := transformers.NewDefaultTransformer(input)
+	transformReader, err := transformer.GetReader()
+	if err != nil {
+		return sigerr.FormatError{Err: fmt.Errorf("Error getting transformer reader: %w", err)}
+	}
+
+	signed, err := signers.SignPecoff(transformReader, signerCert, opts.Filename, hash, opts.OpusParams(input), primary, ctx)
+	if err != nil {
+		return fmt.Errorf("Error signing file: %w", err)
+	}
+
+	return transformer.Apply(outfile, "application/x-binary-patch", bytes.NewReader(signed))
+}
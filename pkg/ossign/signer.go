@@ -0,0 +1,236 @@
+package ossign
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+
+	"github.com/ossign/ossign/pkg/sigerr"
+	"github.com/sassoftware/relic/v8/lib/certloader"
+	rvfs "github.com/sassoftware/relic/v8/lib/vfs"
+)
+
+type SignFunc func(*rvfs.File, *certloader.Certificate, *SignOptions, *rvfs.File, context.Context) error
+
+var MapTypeToFunc = map[SignatureType]SignFunc{
+	PowershellSignature:  SignPowershell,
+	PecoffSignature:      SignPecoff,
+	MsiSignature:         SignMsi,
+	AppxSignature:        SignAppx,
+	AppmanifestSignature: SignAppmanifest,
+	DmgSignature:         SignDmg,
+	MachosSignature:      SignMachos,
+	CabSignature:         SignCab,
+	JarSignature:         SignJar,
+	ApkSignature:         SignApk,
+}
+
+// Parameter name for nesting a new signature inside an existing one instead
+// of replacing it. Only PE files support this.
+const ParamAppend = "append"
+
+// Options for a single signing operation
+type SignOptions struct {
+	// Name of the file being signed. PowerShell scripts are recognized by
+	// their extension, and the name is used in log messages.
+	Filename string
+	// Format specific parameters, overriding those of the configuration
+	Params map[string]string
+}
+
+func (o *SignOptions) GetParamDefault(key, def string) string {
+	if val, ok := o.Params[key]; ok {
+		return val
+	}
+	return def
+}
+
+// Keys that may be used by several signing operations at the same time
+// implement ConcurrentKey. Signer serializes the operations of other keys.
+type ConcurrentKey interface {
+	SignsConcurrently() bool
+}
+
+// A Signer holds the certificate and key loaded from a SigningConfig, so that
+// any number of files can be signed after logging in once. It is safe for
+// concurrent use.
+type Signer struct {
+	Config *SigningConfig
+	Cert   *certloader.Certificate
+
+	// held while signing with a key that is not a ConcurrentKey
+	mu sync.Mutex
+}
+
+// Release the key, e.g. log out of a PKCS#11 token. The Signer cannot be used
+// afterwards.
+func (s *Signer) Close() error {
+	return closeKey(s.Cert)
+}
+
+func closeKey(cert *certloader.Certificate) error {
+	if closer, ok := cert.PrivateKey.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// Take the signing lock unless the key may sign several files at once, which
+// in-memory keys always can. The returned function releases it.
+func (s *Signer) lock() func() {
+	switch key := s.Cert.PrivateKey.(type) {
+	case *rsa.PrivateKey, *ecdsa.PrivateKey, ed25519.PrivateKey:
+		return func() {}
+	case ConcurrentKey:
+		if key.SignsConcurrently() {
+			return func() {}
+		}
+	}
+	s.mu.Lock()
+	return s.mu.Unlock
+}
+
+// Create the timestamper and signing key described by the configuration
+func NewSigner(ctx context.Context, c *SigningConfig) (*Signer, error) {
+	timestamper, err := c.GetTimestamper()
+	if err != nil {
+		return nil, err
+	}
+
+	// Check the digest algorithms before logging in to any remote service
+	if c.HashAlgorithm != "" {
+		if _, err := ParseHashAlgorithm(c.HashAlgorithm); err != nil {
+			return nil, err
+		}
+	}
+	for format, fc := range c.Formats {
+		if fc.HashAlgorithm == "" {
+			continue
+		}
+		if _, err := ParseHashAlgorithm(fc.HashAlgorithm); err != nil {
+			return nil, fmt.Errorf("%s: %w", format, err)
+		}
+	}
+
+	cert, err := c.GetSigner(timestamper, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.HashAlgorithm != "" {
+		hash, _ := ParseHashAlgorithm(c.HashAlgorithm)
+		if err := checkKeyHash(cert, hash); err != nil {
+			closeKey(cert)
+			return nil, err
+		}
+	}
+	for format, fc := range c.Formats {
+		if fc.HashAlgorithm == "" {
+			continue
+		}
+		hash, _ := ParseHashAlgorithm(fc.HashAlgorithm)
+		if err := checkKeyHash(cert, hash); err != nil {
+			closeKey(cert)
+			return nil, fmt.Errorf("%s: %w", format, err)
+		}
+	}
+
+	return &Signer{Config: c, Cert: cert}, nil
+}
+
+// Sign the contents of r, which holds size bytes of the given format, and
+// return the signed file. AutoSignature detects the format from the contents.
+func (s *Signer) Sign(ctx context.Context, r io.ReaderAt, size int64, format SignatureType, opts *SignOptions) (io.Reader, error) {
+	blob, err := io.ReadAll(io.NewSectionReader(r, 0, size))
+	if err != nil {
+		return nil, fmt.Errorf("Error reading input: %w", err)
+	}
+
+	if format == "" || format == AutoSignature {
+		filename := ""
+		if opts != nil {
+			filename = opts.Filename
+		}
+		if format, err = DetectSignatureType(bytes.NewReader(blob), size, filename); err != nil {
+			return nil, fmt.Errorf("Error detecting signature type: %w", err)
+		}
+		log.Printf("Detected signature type %s for %s", format, filename)
+
+		if MapTypeToFunc[format] == nil {
+			return nil, sigerr.FormatError{Err: fmt.Errorf("signing %s files is not supported", format)}
+		}
+	}
+
+	signFunc := MapTypeToFunc[format]
+	if signFunc == nil {
+		return nil, sigerr.ConfigError{Err: fmt.Errorf("unsupported sign type: %s", format)}
+	}
+
+	sopts := s.mergeOptions(format, opts)
+	hash, err := sopts.HashFunc()
+	if err != nil {
+		return nil, err
+	}
+	if err := checkKeyHash(s.Cert, hash); err != nil {
+		return nil, err
+	}
+	// GetSigner only knew the configured format
+	if format != s.Config.SignatureType {
+		if err := s.Config.CertificatePolicy.checkUsage(s.Cert.Leaf, format); err != nil {
+			return nil, err
+		}
+	}
+
+	input := rvfs.New(blob, sopts.Filename)
+	output := rvfs.New([]byte{}, sopts.Filename)
+
+	defer s.lock()()
+	if err := signFunc(input, s.Cert, sopts, output, ctx); err != nil {
+		return nil, err
+	}
+
+	return bytes.NewReader(output.Bytes()), nil
+}
+
+// Combine per-call options with the parameters from the configuration
+func (s *Signer) mergeOptions(format SignatureType, opts *SignOptions) *SignOptions {
+	merged := &SignOptions{Params: make(map[string]string)}
+	for k, v := range s.Config.Params {
+		merged.Params[k] = v
+	}
+	if s.Config.Description != "" {
+		merged.Params[ParamDescription] = s.Config.Description
+	}
+	if s.Config.URL != "" {
+		merged.Params[ParamURL] = s.Config.URL
+	}
+	if s.Config.DescriptionFromVersionInfo {
+		merged.Params[ParamDescriptionFromVersionInfo] = "true"
+	}
+	if s.Config.HashAlgorithm != "" {
+		merged.Params[ParamHashAlgorithm] = s.Config.HashAlgorithm
+	}
+	if s.Config.AppendSignature {
+		merged.Params[ParamAppend] = "true"
+	}
+	if hash := s.Config.Formats[format].HashAlgorithm; hash != "" {
+		merged.Params[ParamHashAlgorithm] = hash
+	}
+	if opts != nil {
+		merged.Filename = opts.Filename
+		for k, v := range opts.Params {
+			merged.Params[k] = v
+		}
+	}
+	// only a configured digest is rejected if the key cannot use it
+	if merged.Params[ParamHashAlgorithm] == "" && s.Cert != nil {
+		merged.Params[ParamHashAlgorithm] = hashAlgorithmName(defaultKeyHash(s.Cert))
+	}
+	return merged
+}
@@ -0,0 +1,31 @@
+package ossign
+
+import (
+	"crypto"
+	"testing"
+
+	"github.com/sassoftware/relic/v8/lib/certloader"
+	"github.com/stretchr/testify/assert"
+)
+
+// A remote key that does not declare itself goroutine-safe
+type serialKey struct {
+	crypto.Signer
+}
+
+func TestSignerLock(t *testing.T) {
+	key, leaf := testCertificate(t)
+
+	s := &Signer{Cert: &certloader.Certificate{Leaf: leaf, PrivateKey: key}}
+	unlock := s.lock()
+	assert.True(t, s.mu.TryLock(), "in-memory keys sign concurrently")
+	s.mu.Unlock()
+	unlock()
+
+	s = &Signer{Cert: &certloader.Certificate{Leaf: leaf, PrivateKey: serialKey{key}}}
+	unlock = s.lock()
+	assert.False(t, s.mu.TryLock(), "other keys are serialized")
+	unlock()
+	assert.True(t, s.mu.TryLock())
+	s.mu.Unlock()
+}
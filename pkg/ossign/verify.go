@@ -0,0 +1,119 @@
+package ossign
+
+import (
+	"archive/zip"
+	"crypto"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ossign/ossign/pkg/apk"
+	"github.com/ossign/ossign/pkg/authenticode"
+	"github.com/ossign/ossign/pkg/sigerr"
+	"github.com/sassoftware/relic/v8/lib/pkcs9"
+	"github.com/sassoftware/relic/v8/lib/signjar"
+)
+
+// A verified signature along with the digest information that was checked
+type VerifiedSignature struct {
+	pkcs9.TimestampedSignature
+	HashFunc     crypto.Hash
+	PageHashFunc crypto.Hash
+	// Set for signatures nested inside another signature
+	Nested bool
+	// Format of the verified file, as detected when none was given
+	Format SignatureType
+}
+
+// Verify all signatures on a file of the given format, including its digests.
+// Chains are not checked against a trust store. The filename is needed to
+// select the signature style of PowerShell files.
+func Verify(r io.ReaderAt, size int64, format SignatureType, filename string) ([]VerifiedSignature, error) {
+	if format == "" || format == AutoSignature {
+		detected, err := DetectSignatureType(r, size, filename)
+		if err != nil {
+			return nil, err
+		}
+		format = detected
+	}
+
+	sigs, err := verify(io.NewSectionReader(r, 0, size), format, filename)
+	if err != nil {
+		if !errors.As(err, &sigerr.FormatError{}) {
+			err = sigerr.VerifyError{Err: err}
+		}
+		return nil, err
+	}
+	for i := range sigs {
+		sigs[i].Format = format
+	}
+	return sigs, nil
+}
+
+func verify(f *io.SectionReader, format SignatureType, filename string) ([]VerifiedSignature, error) {
+	switch format {
+	case PecoffSignature:
+		pesigs, err := authenticode.VerifyPE(f, false)
+		if err != nil {
+			return nil, err
+		}
+		sigs := make([]VerifiedSignature, len(pesigs))
+		for i, sig := range pesigs {
+			sigs[i] = VerifiedSignature{
+				TimestampedSignature: sig.TimestampedSignature,
+				HashFunc:             sig.ImageHashFunc,
+				PageHashFunc:         sig.PageHashFunc,
+				Nested:               sig.Nested,
+			}
+		}
+		return sigs, nil
+	case MsiSignature:
+		sig, err := authenticode.VerifyMSI(f, false)
+		if err != nil {
+			return nil, err
+		}
+		return []VerifiedSignature{{TimestampedSignature: sig.TimestampedSignature, HashFunc: sig.HashFunc}}, nil
+	case CabSignature:
+		sig, err := authenticode.VerifyCab(f, false)
+		if err != nil {
+			return nil, err
+		}
+		return []VerifiedSignature{{TimestampedSignature: sig.TimestampedSignature, HashFunc: sig.HashFunc}}, nil
+	case JarSignature:
+		zr, err := zip.NewReader(f, f.Size())
+		if err != nil {
+			return nil, sigerr.FormatError{Err: err}
+		}
+		jarsigs, err := signjar.Verify(zr, false)
+		if err != nil {
+			return nil, err
+		}
+		sigs := make([]VerifiedSignature, len(jarsigs))
+		for i, sig := range jarsigs {
+			sigs[i] = VerifiedSignature{TimestampedSignature: sig.TimestampedSignature, HashFunc: sig.Hash}
+		}
+		return sigs, nil
+	case ApkSignature:
+		apksigs, err := apk.Verify(f, f.Size())
+		if err != nil {
+			return nil, err
+		}
+		sigs := make([]VerifiedSignature, len(apksigs))
+		for i, sig := range apksigs {
+			sigs[i] = VerifiedSignature{TimestampedSignature: sig.TimestampedSignature, HashFunc: sig.Hash}
+		}
+		return sigs, nil
+	case PowershellSignature:
+		style, ok := authenticode.GetSigStyle(filename)
+		if !ok {
+			return nil, sigerr.FormatError{Err: fmt.Errorf("unknown signature style %s", filename)}
+		}
+		sig, err := authenticode.VerifyPowershell(f, style, false)
+		if err != nil {
+			return nil, err
+		}
+		return []VerifiedSignature{{TimestampedSignature: sig.TimestampedSignature, HashFunc: sig.HashFunc}}, nil
+	default:
+		return nil, sigerr.FormatError{Err: fmt.Errorf("verifying %s files is not supported", format)}
+	}
+}
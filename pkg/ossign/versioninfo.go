@@ -0,0 +1,211 @@
+package ossign
+
+import (
+	"debug/pe"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf16"
+)
+
+const (
+	rtVersion             = 16
+	resourceDirectory     = 2
+	resourceSubdirFlag    = 0x80000000
+	resourceDirHeader     = 16
+	resourceEntrySize     = 8
+	resourceDataEntrySize = 16
+)
+
+var errNoVersionInfo = errors.New("no VERSIONINFO resource found")
+
+// Read the strings of the first string table in the VERSIONINFO resource of a
+// PE file, such as FileDescription, ProductName and CompanyName.
+func ReadVersionInfo(r io.ReaderAt) (map[string]string, error) {
+	f, err := pe.NewFile(r)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var dir pe.DataDirectory
+	switch hdr := f.OptionalHeader.(type) {
+	case *pe.OptionalHeader32:
+		if hdr.NumberOfRvaAndSizes > resourceDirectory {
+			dir = hdr.DataDirectory[resourceDirectory]
+		}
+	case *pe.OptionalHeader64:
+		if hdr.NumberOfRvaAndSizes > resourceDirectory {
+			dir = hdr.DataDirectory[resourceDirectory]
+		}
+	}
+	if dir.VirtualAddress == 0 || dir.Size == 0 {
+		return nil, errNoVersionInfo
+	}
+
+	rsrc, err := readRVA(f, dir.VirtualAddress, dir.Size)
+	if err != nil {
+		return nil, err
+	}
+
+	// Resources are stored as a three level tree of type, name and language.
+	// Take the first name and language of the version resource.
+	offset, err := findResourceEntry(rsrc, 0, rtVersion)
+	if err != nil {
+		return nil, err
+	}
+	for level := 0; level < 2; level++ {
+		if offset&resourceSubdirFlag == 0 {
+			return nil, fmt.Errorf("malformed resource directory")
+		}
+		if offset, err = findResourceEntry(rsrc, offset&^resourceSubdirFlag, -1); err != nil {
+			return nil, err
+		}
+	}
+	if offset&resourceSubdirFlag != 0 || int(offset)+resourceDataEntrySize > len(rsrc) {
+		return nil, fmt.Errorf("malformed resource directory")
+	}
+
+	dataRVA := binary.LittleEndian.Uint32(rsrc[offset:])
+	dataSize := binary.LittleEndian.Uint32(rsrc[offset+4:])
+	blob, err := readRVA(f, dataRVA, dataSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseVersionInfo(blob)
+}
+
+// Find the entry with the given ID in the resource directory at offset, or the
+// first entry if id is negative, and return its data offset
+func findResourceEntry(rsrc []byte, offset uint32, id int) (uint32, error) {
+	if int(offset)+resourceDirHeader > len(rsrc) {
+		return 0, fmt.Errorf("malformed resource directory")
+	}
+	named := int(binary.LittleEndian.Uint16(rsrc[offset+12:]))
+	ids := int(binary.LittleEndian.Uint16(rsrc[offset+14:]))
+
+	entries := rsrc[offset+resourceDirHeader:]
+	for i := 0; i < named+ids; i++ {
+		if (i+1)*resourceEntrySize > len(entries) {
+			return 0, fmt.Errorf("malformed resource directory")
+		}
+		entry := entries[i*resourceEntrySize:]
+		name := binary.LittleEndian.Uint32(entry)
+		if id < 0 || (name&resourceSubdirFlag == 0 && int(name) == id) {
+			return binary.LittleEndian.Uint32(entry[4:]), nil
+		}
+	}
+
+	return 0, errNoVersionInfo
+}
+
+func readRVA(f *pe.File, rva, size uint32) ([]byte, error) {
+	for _, sec := range f.Sections {
+		// compared in 64 bits, so that crafted addresses cannot wrap around
+		if rva < sec.VirtualAddress || uint64(rva) >= uint64(sec.VirtualAddress)+uint64(max(sec.VirtualSize, sec.Size)) {
+			continue
+		}
+		start := rva - sec.VirtualAddress
+		if start > sec.Size || size > sec.Size-start {
+			return nil, fmt.Errorf("resource data extends past section %s", sec.Name)
+		}
+		blob := make([]byte, size)
+		if _, err := sec.ReadAt(blob, int64(start)); err != nil {
+			return nil, err
+		}
+		return blob, nil
+	}
+	return nil, fmt.Errorf("address 0x%x is not in any section", rva)
+}
+
+// A node of the VS_VERSIONINFO structure
+type versionNode struct {
+	key      string
+	value    []byte
+	text     bool
+	children []versionNode
+}
+
+func parseVersionInfo(blob []byte) (map[string]string, error) {
+	root, _, err := parseVersionNode(blob, 0)
+	if err != nil {
+		return nil, err
+	}
+	if root.key != "VS_VERSION_INFO" {
+		return nil, fmt.Errorf("unexpected version resource key %q", root.key)
+	}
+
+	for _, child := range root.children {
+		if child.key != "StringFileInfo" || len(child.children) == 0 {
+			continue
+		}
+		strs := make(map[string]string)
+		for _, str := range child.children[0].children {
+			strs[str.key] = decodeUTF16(str.value)
+		}
+		return strs, nil
+	}
+
+	return nil, errNoVersionInfo
+}
+
+func parseVersionNode(blob []byte, offset int) (node versionNode, end int, err error) {
+	if offset+6 > len(blob) {
+		return node, 0, fmt.Errorf("truncated version resource")
+	}
+	length := int(binary.LittleEndian.Uint16(blob[offset:]))
+	valueLength := int(binary.LittleEndian.Uint16(blob[offset+2:]))
+	node.text = binary.LittleEndian.Uint16(blob[offset+4:]) == 1
+	end = offset + length
+	if length < 6 || end > len(blob) {
+		return node, 0, fmt.Errorf("truncated version resource")
+	}
+	blob = blob[:end]
+
+	// the key is a NUL-terminated UTF-16 string
+	pos := offset + 6
+	keyStart := pos
+	for pos+1 < end && (blob[pos] != 0 || blob[pos+1] != 0) {
+		pos += 2
+	}
+	node.key = decodeUTF16(blob[keyStart:pos])
+	pos = align4(pos + 2)
+
+	// text values are measured in characters
+	if node.text {
+		valueLength *= 2
+	}
+	if pos+valueLength > end {
+		valueLength = max(end-pos, 0)
+	}
+	if valueLength > 0 {
+		node.value = blob[pos : pos+valueLength]
+	}
+	pos = align4(pos + valueLength)
+
+	for pos < end {
+		child, next, err := parseVersionNode(blob, pos)
+		if err != nil {
+			return node, 0, err
+		}
+		node.children = append(node.children, child)
+		pos = align4(next)
+	}
+
+	return node, end, nil
+}
+
+func align4(n int) int {
+	return (n + 3) &^ 3
+}
+
+func decodeUTF16(b []byte) string {
+	u := make([]uint16, len(b)/2)
+	for i := range u {
+		u[i] = binary.LittleEndian.Uint16(b[2*i:])
+	}
+	return strings.TrimRight(string(utf16.Decode(u)), "\x00")
+}
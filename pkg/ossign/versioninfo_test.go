@@ -0,0 +1,121 @@
+package ossign
+
+import (
+	"bytes"
+	"debug/pe"
+	"encoding/binary"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func encodeUTF16(s string) []byte {
+	u := utf16.Encode([]rune(s + "\x00"))
+	b := make([]byte, 2*len(u))
+	for i, c := range u {
+		binary.LittleEndian.PutUint16(b[2*i:], c)
+	}
+	return b
+}
+
+func pad4(b []byte) []byte {
+	for len(b)%4 != 0 {
+		b = append(b, 0)
+	}
+	return b
+}
+
+// Encode a VS_VERSIONINFO node with a text value, or a binary one if text is
+// empty
+func versionBlock(key, text string, children ...[]byte) []byte {
+	var value []byte
+	valueLength, typ := 0, uint16(0)
+	if text != "" {
+		value = encodeUTF16(text)
+		valueLength, typ = len(value)/2, 1
+	}
+
+	blob := make([]byte, 6)
+	blob = pad4(append(blob, encodeUTF16(key)...))
+	blob = append(blob, value...)
+	for _, child := range children {
+		blob = append(pad4(blob), child...)
+	}
+
+	binary.LittleEndian.PutUint16(blob, uint16(len(blob)))
+	binary.LittleEndian.PutUint16(blob[2:], uint16(valueLength))
+	binary.LittleEndian.PutUint16(blob[4:], typ)
+	return blob
+}
+
+func TestParseVersionInfo(t *testing.T) {
+	blob := versionBlock("VS_VERSION_INFO", "",
+		versionBlock("StringFileInfo", "",
+			versionBlock("040904b0", "",
+				versionBlock("CompanyName", "Example Corp"),
+				versionBlock("FileDescription", "Example Installer"),
+				versionBlock("ProductName", "Example"),
+			),
+		),
+		versionBlock("VarFileInfo", ""),
+	)
+
+	info, err := parseVersionInfo(blob)
+	require.NoError(t, err)
+	assert.Equal(t, "Example Corp", info["CompanyName"])
+	assert.Equal(t, "Example Installer", info["FileDescription"])
+	assert.Equal(t, "Example", info["ProductName"])
+}
+
+func TestParseVersionInfoMissingStrings(t *testing.T) {
+	_, err := parseVersionInfo(versionBlock("VS_VERSION_INFO", "", versionBlock("VarFileInfo", "")))
+	assert.ErrorIs(t, err, errNoVersionInfo)
+
+	_, err = parseVersionInfo([]byte{0xff, 0x00, 0x00})
+	assert.Error(t, err)
+}
+
+func TestReadRVA(t *testing.T) {
+	f := &pe.File{Sections: []*pe.Section{{
+		SectionHeader: pe.SectionHeader{Name: ".rsrc", VirtualAddress: 0x1000, VirtualSize: 0x100, Size: 0x100},
+		ReaderAt:      bytes.NewReader(bytes.Repeat([]byte{0xab}, 0x100)),
+	}}}
+
+	blob, err := readRVA(f, 0x1010, 0x10)
+	require.NoError(t, err)
+	assert.Equal(t, bytes.Repeat([]byte{0xab}, 0x10), blob)
+
+	_, err = readRVA(f, 0x10f0, 0x20)
+	assert.ErrorContains(t, err, "extends past section")
+
+	// start+size wraps around in 32 bits
+	_, err = readRVA(f, 0x1010, 0xfffffff8)
+	assert.ErrorContains(t, err, "extends past section")
+
+	_, err = readRVA(f, 0xfffffff0, 0x10)
+	assert.ErrorContains(t, err, "not in any section")
+}
+
+func TestOpusParams(t *testing.T) {
+	s := &Signer{Config: &SigningConfig{
+		URL:    "https://example.com",
+		Params: map[string]string{ParamDescription: "From params"},
+	}}
+
+	opus := s.mergeOptions(PecoffSignature, nil).OpusParams(nil)
+	assert.Equal(t, "From params", opus.Description)
+	assert.Equal(t, "https://example.com", opus.URL)
+
+	s.Config.Description = "From config"
+	opus = s.mergeOptions(PecoffSignature, &SignOptions{Params: map[string]string{ParamURL: "https://example.org"}}).OpusParams(nil)
+	assert.Equal(t, "From config", opus.Description)
+	assert.Equal(t, "https://example.org", opus.URL)
+
+	// a file without VERSIONINFO falls back to the default description
+	s = &Signer{Config: &SigningConfig{DescriptionFromVersionInfo: true}}
+	opus = s.mergeOptions(PecoffSignature, nil).OpusParams(bytes.NewReader(fakePE()))
+	assert.Equal(t, "This software has been signed by OSSign", opus.Description)
+	assert.Equal(t, "https://ossign.org", opus.URL)
+}
@@ -0,0 +1,19 @@
+package pkcs11
+
+// Options select the module, token and key used for signing
+type Options struct {
+	// Path to the PKCS#11 module, e.g. /usr/lib/softhsm/libsofthsm2.so
+	Module string
+	// The token is selected by slot number, label or serial number. When none
+	// is given, the module must expose exactly one token.
+	Slot        *uint
+	TokenLabel  string
+	TokenSerial string
+	// The key is selected by label and/or ID (hex, colons are ignored)
+	KeyLabel string
+	KeyId    string
+	Pin      string
+	// PEM file with the certificate chain. Without it, the certificate with
+	// the same ID as the key and any other certificates on the token are used.
+	CertificateFile string
+}
@@ -0,0 +1,444 @@
+//go:build cgo
+
+package pkcs11
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/miekg/pkcs11"
+	"github.com/ossign/ossign/pkg/internal/leaf"
+	"github.com/ossign/ossign/pkg/sigerr"
+	"github.com/sassoftware/relic/v8/config"
+	"github.com/sassoftware/relic/v8/lib/certloader"
+	"github.com/sassoftware/relic/v8/lib/pkcs9"
+	"github.com/sassoftware/relic/v8/lib/x509tools"
+)
+
+type Pkcs11Key struct {
+	kconf   *config.KeyConfig
+	ctx     *pkcs11.Ctx
+	session pkcs11.SessionHandle
+	priv    pkcs11.ObjectHandle
+	keyType uint
+	pub     crypto.PublicKey
+	id      []byte
+
+	// what Close has to undo
+	finalize, opened, loggedIn bool
+
+	// a session can only run one operation at a time
+	mu sync.Mutex
+}
+
+func (k *Pkcs11Key) Config() *config.KeyConfig { return k.kconf }
+func (k *Pkcs11Key) Certificate() []byte       { return nil }
+func (k *Pkcs11Key) GetID() []byte             { return k.id }
+func (k *Pkcs11Key) Public() crypto.PublicKey  { return k.pub }
+func (k *Pkcs11Key) ImportCertificate(cert *x509.Certificate) error {
+	return fmt.Errorf("importing certificate not supported for Pkcs11Key")
+}
+
+// Operations on the session are serialized by the key itself
+func (k *Pkcs11Key) SignsConcurrently() bool { return true }
+
+func (k *Pkcs11Key) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return k.SignContext(context.Background(), digest, opts)
+}
+
+func (k *Pkcs11Key) SignContext(ctx context.Context, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	var mech *pkcs11.Mechanism
+	switch k.keyType {
+	case pkcs11.CKK_RSA:
+		if pss, ok := opts.(*rsa.PSSOptions); ok {
+			params, err := pssParams(pss, k.pub.(*rsa.PublicKey))
+			if err != nil {
+				return nil, err
+			}
+			mech = pkcs11.NewMechanism(pkcs11.CKM_RSA_PKCS_PSS, params)
+		} else {
+			// CKM_RSA_PKCS only pads, so the DigestInfo is added here
+			var ok bool
+			digest, ok = x509tools.MarshalDigest(opts.HashFunc(), digest)
+			if !ok {
+				return nil, fmt.Errorf("unsupported digest algorithm %s", opts.HashFunc())
+			}
+			mech = pkcs11.NewMechanism(pkcs11.CKM_RSA_PKCS, nil)
+		}
+	case pkcs11.CKK_ECDSA:
+		mech = pkcs11.NewMechanism(pkcs11.CKM_ECDSA, nil)
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if err := k.ctx.SignInit(k.session, []*pkcs11.Mechanism{mech}, k.priv); err != nil {
+		return nil, sigerr.RemoteSigningError{Err: fmt.Errorf("initializing signature: %w", err)}
+	}
+	sig, err := k.ctx.Sign(k.session, digest)
+	if err != nil {
+		return nil, sigerr.RemoteSigningError{Err: fmt.Errorf("signing digest: %w", err)}
+	}
+
+	if k.keyType == pkcs11.CKK_ECDSA {
+		// repack as ASN.1
+		unpacked, err := x509tools.UnpackEcdsaSignature(sig)
+		if err != nil {
+			return nil, err
+		}
+		sig = unpacked.Marshal()
+	}
+	return sig, nil
+}
+
+func pssParams(opts *rsa.PSSOptions, pub *rsa.PublicKey) ([]byte, error) {
+	var hashAlg, mgf uint
+	switch opts.Hash {
+	case crypto.SHA256:
+		hashAlg, mgf = pkcs11.CKM_SHA256, pkcs11.CKG_MGF1_SHA256
+	case crypto.SHA384:
+		hashAlg, mgf = pkcs11.CKM_SHA384, pkcs11.CKG_MGF1_SHA384
+	case crypto.SHA512:
+		hashAlg, mgf = pkcs11.CKM_SHA512, pkcs11.CKG_MGF1_SHA512
+	default:
+		return nil, fmt.Errorf("unsupported digest algorithm %s for PSS", opts.Hash)
+	}
+	saltLength := opts.SaltLength
+	switch saltLength {
+	case rsa.PSSSaltLengthAuto:
+		saltLength = pub.Size() - 2 - opts.Hash.Size()
+	case rsa.PSSSaltLengthEqualsHash:
+		saltLength = opts.Hash.Size()
+	}
+	return pkcs11.NewPSSParams(hashAlg, mgf, uint(saltLength)), nil
+}
+
+// Open the token, log in and find the signing key and its certificates
+func NewPkcs11Key(opts Options, ctx context.Context, timestamper pkcs9.Timestamper) (*certloader.Certificate, error) {
+	if opts.Module == "" {
+		return nil, sigerr.ConfigError{Err: errors.New("no PKCS#11 module configured")}
+	}
+	if opts.KeyLabel == "" && opts.KeyId == "" {
+		return nil, sigerr.ConfigError{Err: errors.New("a key label or ID is required")}
+	}
+
+	p11 := pkcs11.New(opts.Module)
+	if p11 == nil {
+		return nil, sigerr.ConfigError{Err: fmt.Errorf("loading PKCS#11 module %s failed", opts.Module)}
+	}
+	key := &Pkcs11Key{
+		kconf: &config.KeyConfig{ID: opts.KeyId, Label: opts.KeyLabel, Token: "pkcs11"},
+		ctx:   p11,
+	}
+	certs, err := key.open(opts)
+	if err != nil {
+		key.Close()
+		return nil, err
+	}
+
+	return &certloader.Certificate{
+		Leaf:         certs[0],
+		Certificates: certs,
+		PrivateKey:   key,
+		KeyName:      opts.KeyLabel,
+		Timestamper:  timestamper,
+	}, nil
+}
+
+func (k *Pkcs11Key) open(opts Options) ([]*x509.Certificate, error) {
+	if err := k.ctx.Initialize(); err == nil {
+		k.finalize = true
+	} else if !errors.Is(err, pkcs11.Error(pkcs11.CKR_CRYPTOKI_ALREADY_INITIALIZED)) {
+		return nil, sigerr.ConfigError{Err: fmt.Errorf("initializing PKCS#11 module: %w", err)}
+	}
+
+	slot, err := findSlot(k.ctx, opts)
+	if err != nil {
+		return nil, sigerr.ConfigError{Err: err}
+	}
+
+	if k.session, err = k.ctx.OpenSession(slot, pkcs11.CKF_SERIAL_SESSION); err != nil {
+		return nil, sigerr.RemoteSigningError{Err: fmt.Errorf("opening session: %w", err)}
+	}
+	k.opened = true
+	if err := k.ctx.Login(k.session, pkcs11.CKU_USER, opts.Pin); err == nil {
+		k.loggedIn = true
+	} else if !errors.Is(err, pkcs11.Error(pkcs11.CKR_USER_ALREADY_LOGGED_IN)) {
+		return nil, sigerr.CredentialError{Err: fmt.Errorf("logging in to token: %w", err)}
+	}
+
+	if err := k.load(opts); err != nil {
+		return nil, err
+	}
+	return k.certificates(opts)
+}
+
+// Log out, close the session and unload the module. Only what NewPkcs11Key
+// did itself is undone, so a module initialized elsewhere in the process
+// stays usable.
+func (k *Pkcs11Key) Close() error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	var errs []error
+	if k.loggedIn {
+		if err := k.ctx.Logout(k.session); err != nil {
+			errs = append(errs, fmt.Errorf("logging out of token: %w", err))
+		}
+		k.loggedIn = false
+	}
+	if k.opened {
+		if err := k.ctx.CloseSession(k.session); err != nil {
+			errs = append(errs, fmt.Errorf("closing session: %w", err))
+		}
+		k.opened = false
+	}
+	if k.finalize {
+		if err := k.ctx.Finalize(); err != nil {
+			errs = append(errs, fmt.Errorf("finalizing PKCS#11 module: %w", err))
+		}
+		k.finalize = false
+	}
+	k.ctx.Destroy()
+	return errors.Join(errs...)
+}
+
+func findSlot(p11 *pkcs11.Ctx, opts Options) (uint, error) {
+	slots, err := p11.GetSlotList(true)
+	if err != nil {
+		return 0, fmt.Errorf("listing slots: %w", err)
+	}
+
+	var candidates []uint
+	for _, slot := range slots {
+		if opts.Slot != nil && slot != *opts.Slot {
+			continue
+		}
+		info, err := p11.GetTokenInfo(slot)
+		if err != nil {
+			return 0, fmt.Errorf("reading token info of slot %d: %w", slot, err)
+		}
+		if opts.TokenLabel != "" && strings.TrimSpace(info.Label) != opts.TokenLabel {
+			continue
+		}
+		if opts.TokenSerial != "" && strings.TrimSpace(info.SerialNumber) != opts.TokenSerial {
+			continue
+		}
+		// SoftHSM and others always offer an empty slot for a new token
+		if opts.Slot == nil && opts.TokenLabel == "" && opts.TokenSerial == "" && info.Flags&pkcs11.CKF_TOKEN_INITIALIZED == 0 {
+			continue
+		}
+		candidates = append(candidates, slot)
+	}
+
+	switch len(candidates) {
+	case 0:
+		return 0, errors.New("no token found with the configured slot, label or serial")
+	case 1:
+		return candidates[0], nil
+	default:
+		return 0, errors.New("several tokens match, select one by slot, label or serial")
+	}
+}
+
+func (k *Pkcs11Key) load(opts Options) error {
+	template := []*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PRIVATE_KEY)}
+	if opts.KeyLabel != "" {
+		template = append(template, pkcs11.NewAttribute(pkcs11.CKA_LABEL, opts.KeyLabel))
+	}
+	if opts.KeyId != "" {
+		id, err := hex.DecodeString(strings.ReplaceAll(opts.KeyId, ":", ""))
+		if err != nil {
+			return sigerr.ConfigError{Err: fmt.Errorf("parsing key ID: %w", err)}
+		}
+		template = append(template, pkcs11.NewAttribute(pkcs11.CKA_ID, id))
+	}
+
+	objects, err := k.find(template, 2)
+	if err != nil {
+		return sigerr.RemoteSigningError{Err: fmt.Errorf("finding key: %w", err)}
+	}
+	switch len(objects) {
+	case 0:
+		return sigerr.ConfigError{Err: errors.New("no private key found with the configured label or ID")}
+	case 1:
+		k.priv = objects[0]
+	default:
+		return sigerr.ConfigError{Err: errors.New("several private keys match the configured label or ID")}
+	}
+
+	attrs, err := k.ctx.GetAttributeValue(k.session, k.priv, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, nil),
+		pkcs11.NewAttribute(pkcs11.CKA_ID, nil),
+	})
+	if err != nil {
+		return sigerr.RemoteSigningError{Err: fmt.Errorf("reading key attributes: %w", err)}
+	}
+	k.keyType = ulong(attrs[0].Value)
+	k.id = attrs[1].Value
+
+	if k.keyType != pkcs11.CKK_RSA && k.keyType != pkcs11.CKK_ECDSA {
+		return sigerr.ConfigError{Err: fmt.Errorf("unsupported key type %d", k.keyType)}
+	}
+	return nil
+}
+
+// Load the certificate chain from the side file, or from the token, and put
+// the certificate of the key first
+func (k *Pkcs11Key) certificates(opts Options) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	var err error
+	if opts.CertificateFile != "" {
+		blob, err := os.ReadFile(opts.CertificateFile)
+		if err != nil {
+			return nil, sigerr.ConfigError{Err: fmt.Errorf("reading certificate file: %w", err)}
+		}
+		if certs, err = certloader.ParseX509Certificates(blob); err != nil {
+			return nil, sigerr.ConfigError{Err: fmt.Errorf("parsing certificate file: %w", err)}
+		}
+	} else if certs, err = k.tokenCertificates(); err != nil {
+		return nil, err
+	}
+	if len(certs) == 0 {
+		return nil, sigerr.ConfigError{Err: errors.New("no certificate found for the PKCS#11 key")}
+	}
+
+	pub, err := k.publicKey()
+	if err != nil {
+		return nil, err
+	}
+	if pub == nil {
+		// without a public key object the leaf must come first
+		k.pub = certs[0].PublicKey
+		return certs, nil
+	}
+
+	if certs, err = leaf.First(certs, pub, "PKCS#11 key"); err != nil {
+		return nil, err
+	}
+	k.pub = pub
+	return certs, nil
+}
+
+// Read the public key object stored next to the private key, if there is one
+func (k *Pkcs11Key) publicKey() (crypto.PublicKey, error) {
+	template := []*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PUBLIC_KEY)}
+	if len(k.id) > 0 {
+		template = append(template, pkcs11.NewAttribute(pkcs11.CKA_ID, k.id))
+	} else {
+		template = append(template, pkcs11.NewAttribute(pkcs11.CKA_LABEL, k.kconf.Label))
+	}
+	objects, err := k.find(template, 1)
+	if err != nil {
+		return nil, sigerr.RemoteSigningError{Err: fmt.Errorf("finding public key: %w", err)}
+	}
+	if len(objects) == 0 {
+		return nil, nil
+	}
+
+	switch k.keyType {
+	case pkcs11.CKK_RSA:
+		attrs, err := k.ctx.GetAttributeValue(k.session, objects[0], []*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_MODULUS, nil),
+			pkcs11.NewAttribute(pkcs11.CKA_PUBLIC_EXPONENT, nil),
+		})
+		if err != nil {
+			return nil, sigerr.RemoteSigningError{Err: fmt.Errorf("reading public key: %w", err)}
+		}
+		e := new(big.Int).SetBytes(attrs[1].Value)
+		if !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+			return nil, sigerr.RemoteSigningError{Err: errors.New("RSA exponent is out of bounds")}
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(attrs[0].Value), E: int(e.Int64())}, nil
+	default:
+		attrs, err := k.ctx.GetAttributeValue(k.session, objects[0], []*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, nil),
+			pkcs11.NewAttribute(pkcs11.CKA_EC_POINT, nil),
+		})
+		if err != nil {
+			return nil, sigerr.RemoteSigningError{Err: fmt.Errorf("reading public key: %w", err)}
+		}
+		curve, err := x509tools.CurveByDer(attrs[0].Value)
+		if err != nil {
+			return nil, sigerr.RemoteSigningError{Err: err}
+		}
+		x, y := x509tools.DerToPoint(curve.Curve, attrs[1].Value)
+		if x == nil || y == nil {
+			return nil, sigerr.RemoteSigningError{Err: errors.New("invalid elliptic curve point")}
+		}
+		return &ecdsa.PublicKey{Curve: curve.Curve, X: x, Y: y}, nil
+	}
+}
+
+func (k *Pkcs11Key) tokenCertificates() ([]*x509.Certificate, error) {
+	objects, err := k.find([]*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_CERTIFICATE),
+		pkcs11.NewAttribute(pkcs11.CKA_CERTIFICATE_TYPE, pkcs11.CKC_X_509),
+	}, 100)
+	if err != nil {
+		return nil, sigerr.RemoteSigningError{Err: fmt.Errorf("finding certificates: %w", err)}
+	}
+
+	var leaf *x509.Certificate
+	var others []*x509.Certificate
+	for _, obj := range objects {
+		attrs, err := k.ctx.GetAttributeValue(k.session, obj, []*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_VALUE, nil),
+			pkcs11.NewAttribute(pkcs11.CKA_ID, nil),
+		})
+		if err != nil {
+			return nil, sigerr.RemoteSigningError{Err: fmt.Errorf("reading certificate: %w", err)}
+		}
+		cert, err := x509.ParseCertificate(attrs[0].Value)
+		if err != nil {
+			return nil, sigerr.RemoteSigningError{Err: fmt.Errorf("parsing certificate: %w", err)}
+		}
+		if leaf == nil && len(k.id) > 0 && bytes.Equal(attrs[1].Value, k.id) {
+			leaf = cert
+		} else {
+			others = append(others, cert)
+		}
+	}
+	if leaf == nil {
+		return others, nil
+	}
+	return append([]*x509.Certificate{leaf}, others...), nil
+}
+
+func (k *Pkcs11Key) find(template []*pkcs11.Attribute, max int) ([]pkcs11.ObjectHandle, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if err := k.ctx.FindObjectsInit(k.session, template); err != nil {
+		return nil, err
+	}
+	objects, _, err := k.ctx.FindObjects(k.session, max)
+	if finalErr := k.ctx.FindObjectsFinal(k.session); err == nil {
+		err = finalErr
+	}
+	return objects, err
+}
+
+// CK_ULONG attributes are stored in native byte order and size
+func ulong(b []byte) uint {
+	switch len(b) {
+	case 4:
+		return uint(binary.NativeEndian.Uint32(b))
+	case 8:
+		return uint(binary.NativeEndian.Uint64(b))
+	}
+	return 0
+}
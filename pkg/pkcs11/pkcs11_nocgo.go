@@ -0,0 +1,17 @@
+//go:build !cgo
+
+package pkcs11
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ossign/ossign/pkg/sigerr"
+	"github.com/sassoftware/relic/v8/lib/certloader"
+	"github.com/sassoftware/relic/v8/lib/pkcs9"
+)
+
+// PKCS#11 modules are shared libraries, which can only be loaded with cgo
+func NewPkcs11Key(opts Options, ctx context.Context, timestamper pkcs9.Timestamper) (*certloader.Certificate, error) {
+	return nil, sigerr.ConfigError{Err: errors.New("this build of ossign does not support PKCS#11, rebuild it with CGO_ENABLED=1")}
+}
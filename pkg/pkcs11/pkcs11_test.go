@@ -0,0 +1,173 @@
+//go:build cgo
+
+package pkcs11
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/pkcs11"
+	"github.com/ossign/ossign/pkg/sigerr"
+	"github.com/sassoftware/relic/v8/lib/x509tools"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Path of libsofthsm2.so. The tests are skipped without it.
+const softHSMEnv = "OSSIGN_TEST_SOFTHSM_MODULE"
+
+const (
+	testTokenLabel = "ossign-test"
+	testKeyLabel   = "signing"
+	testPin        = "5678"
+)
+
+// Create a SoftHSM token in a temporary directory holding a P-256 key and its
+// certificate, and return the module path
+func setupSoftHSM(t *testing.T) string {
+	module := os.Getenv(softHSMEnv)
+	if module == "" {
+		t.Skipf("%s is not set", softHSMEnv)
+	}
+
+	dir := t.TempDir()
+	conf := filepath.Join(dir, "softhsm2.conf")
+	tokens := filepath.Join(dir, "tokens")
+	require.NoError(t, os.Mkdir(tokens, 0700))
+	require.NoError(t, os.WriteFile(conf, []byte("directories.tokendir = "+tokens+"\nobjectstore.backend = file\n"), 0600))
+	t.Setenv("SOFTHSM2_CONF", conf)
+
+	p11 := pkcs11.New(module)
+	require.NotNil(t, p11)
+	defer p11.Destroy()
+	require.NoError(t, p11.Initialize())
+	defer p11.Finalize()
+
+	slots, err := p11.GetSlotList(true)
+	require.NoError(t, err)
+	require.NotEmpty(t, slots)
+	require.NoError(t, p11.InitToken(slots[0], "1234", testTokenLabel))
+
+	// SoftHSM moves an initialized token to a new slot
+	slots, err = p11.GetSlotList(true)
+	require.NoError(t, err)
+	var slot uint
+	found := false
+	for _, s := range slots {
+		info, err := p11.GetTokenInfo(s)
+		require.NoError(t, err)
+		if strings.TrimSpace(info.Label) == testTokenLabel {
+			slot, found = s, true
+		}
+	}
+	require.True(t, found)
+
+	session, err := p11.OpenSession(slot, pkcs11.CKF_SERIAL_SESSION|pkcs11.CKF_RW_SESSION)
+	require.NoError(t, err)
+	defer p11.CloseSession(session)
+	require.NoError(t, p11.Login(session, pkcs11.CKU_SO, "1234"))
+	require.NoError(t, p11.InitPIN(session, testPin))
+	require.NoError(t, p11.Logout(session))
+	require.NoError(t, p11.Login(session, pkcs11.CKU_USER, testPin))
+	defer p11.Logout(session)
+
+	curve, err := asn1.Marshal(asn1.ObjectIdentifier{1, 2, 840, 10045, 3, 1, 7})
+	require.NoError(t, err)
+	id := []byte{1}
+	pubHandle, _, err := p11.GenerateKeyPair(session,
+		[]*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_EC_KEY_PAIR_GEN, nil)},
+		[]*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+			pkcs11.NewAttribute(pkcs11.CKA_VERIFY, true),
+			pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, curve),
+			pkcs11.NewAttribute(pkcs11.CKA_LABEL, testKeyLabel),
+			pkcs11.NewAttribute(pkcs11.CKA_ID, id),
+		},
+		[]*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+			pkcs11.NewAttribute(pkcs11.CKA_PRIVATE, true),
+			pkcs11.NewAttribute(pkcs11.CKA_SIGN, true),
+			pkcs11.NewAttribute(pkcs11.CKA_LABEL, testKeyLabel),
+			pkcs11.NewAttribute(pkcs11.CKA_ID, id),
+		})
+	require.NoError(t, err)
+
+	attrs, err := p11.GetAttributeValue(session, pubHandle, []*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_EC_POINT, nil)})
+	require.NoError(t, err)
+	x, y := x509tools.DerToPoint(elliptic.P256(), attrs[0].Value)
+	require.NotNil(t, x)
+	pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}
+
+	// a throwaway CA issues the certificate, which keeps the token out of it
+	ca, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "token signer"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, pub, ca)
+	require.NoError(t, err)
+	_, err = p11.CreateObject(session, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_CERTIFICATE),
+		pkcs11.NewAttribute(pkcs11.CKA_CERTIFICATE_TYPE, pkcs11.CKC_X_509),
+		pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+		pkcs11.NewAttribute(pkcs11.CKA_ID, id),
+		pkcs11.NewAttribute(pkcs11.CKA_VALUE, der),
+	})
+	require.NoError(t, err)
+
+	return module
+}
+
+func TestSoftHSM(t *testing.T) {
+	module := setupSoftHSM(t)
+
+	// the empty slot SoftHSM offers next to the token is skipped
+	cert, err := NewPkcs11Key(Options{Module: module, KeyLabel: testKeyLabel, Pin: testPin}, context.Background(), nil)
+	require.NoError(t, err)
+	assert.Equal(t, "token signer", cert.Leaf.Subject.CommonName)
+
+	digest := sha256.Sum256([]byte("hello"))
+	sig, err := cert.Signer().Sign(rand.Reader, digest[:], crypto.SHA256)
+	require.NoError(t, err)
+	assert.True(t, ecdsa.VerifyASN1(cert.Leaf.PublicKey.(*ecdsa.PublicKey), digest[:], sig))
+
+	key := cert.PrivateKey.(*Pkcs11Key)
+	assert.NoError(t, key.Close())
+	assert.NoError(t, key.Close(), "closing twice is harmless")
+}
+
+func TestSoftHSMErrors(t *testing.T) {
+	module := setupSoftHSM(t)
+
+	_, err := NewPkcs11Key(Options{Module: module, KeyLabel: testKeyLabel, Pin: "0000"}, context.Background(), nil)
+	assert.True(t, errors.As(err, &sigerr.CredentialError{}), err)
+
+	_, err = NewPkcs11Key(Options{Module: module, KeyLabel: "missing", Pin: testPin}, context.Background(), nil)
+	assert.True(t, errors.As(err, &sigerr.ConfigError{}), err)
+
+	_, err = NewPkcs11Key(Options{Module: module, TokenLabel: "missing", KeyLabel: testKeyLabel, Pin: testPin}, context.Background(), nil)
+	assert.True(t, errors.As(err, &sigerr.ConfigError{}), err)
+
+	// the failed attempts released the module again
+	cert, err := NewPkcs11Key(Options{Module: module, TokenLabel: testTokenLabel, KeyLabel: testKeyLabel, Pin: testPin}, context.Background(), nil)
+	require.NoError(t, err)
+	assert.NoError(t, cert.PrivateKey.(*Pkcs11Key).Close())
+}
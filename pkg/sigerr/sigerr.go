@@ -0,0 +1,55 @@
+// Package sigerr holds the error types returned by the signing packages so
+// that callers can tell configuration problems apart from failures of a remote
+// key service, a timestamp authority or the file being signed.
+package sigerr
+
+import "fmt"
+
+// The configuration is incomplete or holds an unusable value, such as a
+// certificate that cannot be parsed
+type ConfigError struct {
+	Err error
+}
+
+func (e ConfigError) Error() string { return fmt.Sprintf("configuration error: %v", e.Err) }
+func (e ConfigError) Unwrap() error { return e.Err }
+
+// Credentials for a key service could not be created or were rejected
+type CredentialError struct {
+	Err error
+}
+
+func (e CredentialError) Error() string { return fmt.Sprintf("credential error: %v", e.Err) }
+func (e CredentialError) Unwrap() error { return e.Err }
+
+// A remote key service failed to return a key, certificate or signature
+type RemoteSigningError struct {
+	Err error
+}
+
+func (e RemoteSigningError) Error() string { return fmt.Sprintf("remote signing error: %v", e.Err) }
+func (e RemoteSigningError) Unwrap() error { return e.Err }
+
+// The file is of an unsupported type or could not be parsed
+type FormatError struct {
+	Err error
+}
+
+func (e FormatError) Error() string { return fmt.Sprintf("format error: %v", e.Err) }
+func (e FormatError) Unwrap() error { return e.Err }
+
+// No timestamp could be obtained for the signature
+type TimestampError struct {
+	Err error
+}
+
+func (e TimestampError) Error() string { return fmt.Sprintf("timestamp error: %v", e.Err) }
+func (e TimestampError) Unwrap() error { return e.Err }
+
+// A signature is missing or does not match the signed content
+type VerifyError struct {
+	Err error
+}
+
+func (e VerifyError) Error() string { return fmt.Sprintf("verification failed: %v", e.Err) }
+func (e VerifyError) Unwrap() error { return e.Err }
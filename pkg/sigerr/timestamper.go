@@ -0,0 +1,28 @@
+package sigerr
+
+import (
+	"context"
+
+	"github.com/sassoftware/relic/v8/lib/pkcs7"
+	"github.com/sassoftware/relic/v8/lib/pkcs9"
+)
+
+type timestamper struct {
+	pkcs9.Timestamper
+}
+
+// Wrap a timestamper so that its failures are returned as a TimestampError
+func WrapTimestamper(t pkcs9.Timestamper) pkcs9.Timestamper {
+	if t == nil {
+		return nil
+	}
+	return timestamper{t}
+}
+
+func (t timestamper) Timestamp(ctx context.Context, req *pkcs9.Request) (*pkcs7.ContentInfoSignedData, error) {
+	token, err := t.Timestamper.Timestamp(ctx, req)
+	if err != nil {
+		return nil, TimestampError{Err: err}
+	}
+	return token, nil
+}
@@ -7,26 +7,31 @@ import (
 	"io"
 	"time"
 
+	"github.com/ossign/ossign/pkg/apk"
+	oauthenticode "github.com/ossign/ossign/pkg/authenticode"
+	"github.com/ossign/ossign/pkg/sigerr"
+	"github.com/ossign/ossign/pkg/transformers"
 	"github.com/sassoftware/relic/v8/lib/appmanifest"
 	"github.com/sassoftware/relic/v8/lib/audit"
 	"github.com/sassoftware/relic/v8/lib/authenticode"
+	"github.com/sassoftware/relic/v8/lib/cabfile"
 	"github.com/sassoftware/relic/v8/lib/certloader"
 	"github.com/sassoftware/relic/v8/lib/pkcs9"
 	"github.com/sassoftware/relic/v8/lib/signappx"
+	"github.com/sassoftware/relic/v8/lib/signjar"
 	"github.com/sassoftware/relic/v8/signers"
-	"github.com/spf13/pflag"
 )
 
-func SignPowershell(r io.Reader, cert *certloader.Certificate, filename string, ctx context.Context) ([]byte, error) {
-	common := pflag.NewFlagSet("common", pflag.ExitOnError)
-	common.Bool("no-timestamp", false, "Do not attach a trusted timestamp even if the selected key configures one")
+// Publisher description and URL shown in the Windows "more info" dialog when
+// nothing else is configured
+const (
+	DefaultDescription = "This software has been signed by OSSign"
+	DefaultURL         = "https://ossign.org"
+)
 
+func SignPowershell(r io.Reader, cert *certloader.Certificate, filename string, hash crypto.Hash, opus *authenticode.OpusParams, ctx context.Context) ([]byte, error) {
 	signopts := signers.SignOpts{
-		Hash: crypto.SHA256,
-		Flags: &signers.FlagValues{
-			Defs:   common,
-			Values: map[string]string{},
-		},
+		Hash: hash,
 		Audit: &audit.Info{
 			StartTime:  time.Now(),
 			Attributes: map[string]interface{}{},
@@ -35,18 +40,15 @@ func SignPowershell(r io.Reader, cert *certloader.Certificate, filename string,
 
 	sigStyle, ok := authenticode.GetSigStyle(filename)
 	if !ok {
-		return nil, fmt.Errorf("unknown signature style %s", filename)
+		return nil, sigerr.FormatError{Err: fmt.Errorf("unknown signature style %s", filename)}
 	}
 
 	digest, err := authenticode.DigestPowershell(r, sigStyle, signopts.Hash)
 	if err != nil {
-		return nil, err
+		return nil, sigerr.FormatError{Err: err}
 	}
 
-	patch, ts, err := digest.Sign(ctx, cert, &authenticode.OpusParams{
-		Description: "This software has been signed by OSSign",
-		URL:         "https://ossign.org",
-	})
+	patch, ts, err := digest.Sign(ctx, cert, opus)
 	if err != nil {
 		return nil, err
 	}
@@ -55,16 +57,61 @@ func SignPowershell(r io.Reader, cert *certloader.Certificate, filename string,
 	return signopts.SetBinPatch(patch)
 }
 
-func SignPecoff(r io.Reader, cert *certloader.Certificate, filename string, ctx context.Context) ([]byte, error) {
-	digest, err := authenticode.DigestPE(r, crypto.SHA256, true)
+// Sign a PE image. When primary holds the existing signature of the image,
+// the new signature is nested inside it instead of replacing it.
+func SignPecoff(r io.Reader, cert *certloader.Certificate, filename string, hash crypto.Hash, opus *authenticode.OpusParams, primary []byte, ctx context.Context) ([]byte, error) {
+	// page hashes are only defined for SHA-1 and SHA-256
+	pageHashes := hash == crypto.SHA1 || hash == crypto.SHA256
+	digest, err := authenticode.DigestPE(r, hash, pageHashes)
+	if err != nil {
+		return nil, sigerr.FormatError{Err: err}
+	}
+	patch, ts, err := digest.Sign(ctx, cert, opus)
 	if err != nil {
 		return nil, err
 	}
-	patch, _, err := digest.Sign(ctx, cert, &authenticode.OpusParams{
-		Description: "This software has been signed by OSSign",
-		URL:         "https://ossign.org",
+
+	if primary != nil {
+		sig, err := oauthenticode.NestSignature(primary, ts.Raw)
+		if err != nil {
+			return nil, sigerr.FormatError{Err: err}
+		}
+		if patch, err = digest.MakePatch(sig); err != nil {
+			return nil, err
+		}
+	}
+
+	return patch.Dump(), nil
+}
+
+// Sign a cabinet file. An existing signature or signature reserve is replaced.
+func SignCab(r io.Reader, cert *certloader.Certificate, filename string, hash crypto.Hash, opus *authenticode.OpusParams, ctx context.Context) ([]byte, error) {
+	digest, err := cabfile.Digest(r, hash)
+	if err != nil {
+		return nil, sigerr.FormatError{Err: err}
+	}
+
+	patch, _, err := oauthenticode.SignCabImprint(ctx, digest, cert, &oauthenticode.OpusParams{
+		Description: opus.Description,
+		URL:         opus.URL,
 	})
+	if err != nil {
+		return nil, err
+	}
 
+	return patch.Dump(), nil
+}
+
+// Sign a Java archive. The manifest is updated with the digests of all
+// entries, and the signature file and PKCS#7 block named after alias are added
+// to META-INF.
+func SignJar(r io.Reader, cert *certloader.Certificate, alias string, hash crypto.Hash, ctx context.Context) ([]byte, error) {
+	digest, err := signjar.DigestJarStream(r, hash)
+	if err != nil {
+		return nil, sigerr.FormatError{Err: err}
+	}
+
+	patch, _, err := digest.Sign(ctx, cert, alias, false, false, false)
 	if err != nil {
 		return nil, err
 	}
@@ -72,16 +119,29 @@ func SignPecoff(r io.Reader, cert *certloader.Certificate, filename string, ctx
 	return patch.Dump(), nil
 }
 
-func SignMsi(r io.Reader, cert *certloader.Certificate, filename string, ctx context.Context) ([]byte, error) {
-	sum, err := authenticode.DigestMsiTar(r, crypto.SHA256, false)
+// Sign an Android package with the v2 and v3 schemes, and the v1 (JAR) scheme
+// when v1 is set
+func SignApk(r io.Reader, cert *certloader.Certificate, alias string, v1 bool, hash crypto.Hash, ctx context.Context) ([]byte, error) {
+	inz, err := transformers.ReadZipTar(r)
+	if err != nil {
+		return nil, sigerr.FormatError{Err: err}
+	}
+
+	patch, err := apk.Sign(ctx, inz, cert, hash, apk.SignOptions{V1: v1, Alias: alias})
 	if err != nil {
 		return nil, err
 	}
 
-	ts, err := authenticode.SignMSIImprint(ctx, sum, crypto.SHA256, cert, &authenticode.OpusParams{
-		Description: "This software has been signed by OSSign",
-		URL:         "https://ossign.org",
-	})
+	return patch.Dump(), nil
+}
+
+func SignMsi(r io.Reader, cert *certloader.Certificate, filename string, hash crypto.Hash, opus *authenticode.OpusParams, ctx context.Context) ([]byte, error) {
+	sum, err := authenticode.DigestMsiTar(r, hash, false)
+	if err != nil {
+		return nil, sigerr.FormatError{Err: err}
+	}
+
+	ts, err := authenticode.SignMSIImprint(ctx, sum, hash, cert, opus)
 	if err != nil {
 		return nil, err
 	}
@@ -89,19 +149,22 @@ func SignMsi(r io.Reader, cert *certloader.Certificate, filename string, ctx con
 	return ts.Raw, nil
 }
 
-func SignAppmanifest(r io.Reader, cert *certloader.Certificate, filename string, ctx context.Context) ([]byte, error) {
+func SignAppmanifest(r io.Reader, cert *certloader.Certificate, filename string, hash crypto.Hash, ctx context.Context) ([]byte, error) {
 	blob, err := io.ReadAll(r)
 	if err != nil {
 		return nil, err
 	}
 
-	signed, err := appmanifest.Sign(blob, cert, crypto.SHA256)
+	signed, err := appmanifest.Sign(blob, cert, hash)
+	if err != nil {
+		return nil, err
+	}
 
 	if cert.Timestamper != nil {
 		tsreq := &pkcs9.Request{
 			EncryptedDigest: signed.EncryptedDigest,
 			Legacy:          false,
-			Hash:            crypto.SHA256,
+			Hash:            hash,
 		}
 
 		token, err := cert.Timestamper.Timestamp(ctx, tsreq)
@@ -116,16 +179,13 @@ func SignAppmanifest(r io.Reader, cert *certloader.Certificate, filename string,
 	return signed.Signed, nil
 }
 
-func SignAppx(r io.Reader, cert *certloader.Certificate, filename string, ctx context.Context) ([]byte, error) {
-	digest, err := signappx.DigestAppxTar(r, crypto.SHA256, false)
+func SignAppx(r io.Reader, cert *certloader.Certificate, filename string, hash crypto.Hash, opus *authenticode.OpusParams, ctx context.Context) ([]byte, error) {
+	digest, err := signappx.DigestAppxTar(r, hash, false)
 	if err != nil {
-		return nil, err
+		return nil, sigerr.FormatError{Err: err}
 	}
 
-	patch, _, _, err := digest.Sign(ctx, cert, &authenticode.OpusParams{
-		Description: "This software has been signed by OSSign",
-		URL:         "https://ossign.org",
-	})
+	patch, _, _, err := digest.Sign(ctx, cert, opus)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,140 @@
+package timestamp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/sassoftware/relic/v8/config"
+	"github.com/sassoftware/relic/v8/lib/pkcs7"
+	"github.com/sassoftware/relic/v8/lib/pkcs9"
+	"github.com/sassoftware/relic/v8/lib/pkcs9/tsclient"
+)
+
+const (
+	DefaultTimeout = 30 * time.Second
+	DefaultBackoff = 2 * time.Second
+)
+
+type Config struct {
+	// RFC 3161 timestamp servers, tried in order
+	URLs []string
+	// Microsoft Authenticode (legacy) timestamp servers, tried in order
+	MsURLs []string
+	// Time allowed for a single request
+	Timeout time.Duration
+	// How many more times the whole list is tried after every server failed
+	Retries int
+	// Wait before the first retry, doubled for every following retry
+	Backoff time.Duration
+}
+
+type server struct {
+	url string
+	ts  pkcs9.Timestamper
+}
+
+// A timestamper that fails over between several timestamp servers and
+// retries the list with exponential backoff when all of them fail
+type Timestamper struct {
+	servers   []server
+	msServers []server
+	retries   int
+	backoff   time.Duration
+	timeout   time.Duration
+
+	// replaced in tests to avoid waiting
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+func New(conf Config) (*Timestamper, error) {
+	if len(conf.URLs) == 0 && len(conf.MsURLs) == 0 {
+		return nil, errors.New("no timestamp servers configured")
+	}
+
+	t := &Timestamper{
+		retries: conf.Retries,
+		backoff: conf.Backoff,
+		timeout: conf.Timeout,
+		sleep:   sleep,
+	}
+	if t.timeout <= 0 {
+		t.timeout = DefaultTimeout
+	}
+	if t.backoff <= 0 {
+		t.backoff = DefaultBackoff
+	}
+
+	// one client per server, so that the order and retries are handled here
+	for _, url := range conf.URLs {
+		ts, err := tsclient.New(&config.TimestampConfig{URLs: []string{url}})
+		if err != nil {
+			return nil, err
+		}
+		t.servers = append(t.servers, server{url, ts})
+	}
+	for _, url := range conf.MsURLs {
+		ts, err := tsclient.New(&config.TimestampConfig{MsURLs: []string{url}})
+		if err != nil {
+			return nil, err
+		}
+		t.msServers = append(t.msServers, server{url, ts})
+	}
+
+	return t, nil
+}
+
+func (t *Timestamper) Timestamp(ctx context.Context, req *pkcs9.Request) (*pkcs7.ContentInfoSignedData, error) {
+	servers := t.servers
+	if req.Legacy {
+		servers = t.msServers
+	}
+	if len(servers) == 0 {
+		return nil, errors.New("no timestamp servers configured for this signature type")
+	}
+
+	var lastErr error
+	for attempt := 0; attempt <= t.retries; attempt++ {
+		if attempt > 0 {
+			wait := t.backoff << (attempt - 1)
+			log.Printf("All timestamp servers failed, retrying in %s", wait)
+			if err := t.sleep(ctx, wait); err != nil {
+				return nil, fmt.Errorf("timestamping failed: %w", lastErr)
+			}
+		}
+
+		for _, srv := range servers {
+			token, err := t.try(ctx, srv, req)
+			if err == nil {
+				log.Printf("Timestamp obtained from %s", srv.url)
+				return token, nil
+			}
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			log.Printf("Timestamp server %s failed: %v", srv.url, err)
+			lastErr = err
+		}
+	}
+
+	return nil, fmt.Errorf("timestamping failed on all servers: %w", lastErr)
+}
+
+func (t *Timestamper) try(ctx context.Context, srv server, req *pkcs9.Request) (*pkcs7.ContentInfoSignedData, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	return srv.ts.Timestamp(ctx, req)
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,77 @@
+package timestamp
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sassoftware/relic/v8/lib/pkcs7"
+	"github.com/sassoftware/relic/v8/lib/pkcs9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeServer struct {
+	failures int
+	calls    int
+}
+
+func (f *fakeServer) Timestamp(ctx context.Context, req *pkcs9.Request) (*pkcs7.ContentInfoSignedData, error) {
+	f.calls++
+	if f.calls <= f.failures {
+		return nil, errors.New("unavailable")
+	}
+	return &pkcs7.ContentInfoSignedData{}, nil
+}
+
+func newTestTimestamper(retries int, servers ...*fakeServer) (*Timestamper, *[]time.Duration) {
+	var waits []time.Duration
+	t := &Timestamper{
+		retries: retries,
+		backoff: time.Second,
+		timeout: time.Second,
+		sleep: func(ctx context.Context, d time.Duration) error {
+			waits = append(waits, d)
+			return nil
+		},
+	}
+	for i, srv := range servers {
+		t.servers = append(t.servers, server{url: string(rune('a' + i)), ts: srv})
+	}
+	return t, &waits
+}
+
+func TestFailover(t *testing.T) {
+	first := &fakeServer{failures: 1}
+	second := &fakeServer{}
+	ts, waits := newTestTimestamper(0, first, second)
+
+	_, err := ts.Timestamp(context.Background(), &pkcs9.Request{})
+	require.NoError(t, err)
+	assert.Equal(t, 1, first.calls)
+	assert.Equal(t, 1, second.calls)
+	assert.Empty(t, *waits)
+}
+
+func TestRetryWithBackoff(t *testing.T) {
+	first := &fakeServer{failures: 3}
+	second := &fakeServer{failures: 3}
+	ts, waits := newTestTimestamper(3, first, second)
+
+	_, err := ts.Timestamp(context.Background(), &pkcs9.Request{})
+	require.NoError(t, err)
+	assert.Equal(t, 4, first.calls)
+	assert.Equal(t, 3, second.calls)
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second}, *waits)
+}
+
+func TestAllServersFail(t *testing.T) {
+	ts, _ := newTestTimestamper(1, &fakeServer{failures: 10})
+
+	_, err := ts.Timestamp(context.Background(), &pkcs9.Request{})
+	assert.ErrorContains(t, err, "unavailable")
+
+	_, err = ts.Timestamp(context.Background(), &pkcs9.Request{Legacy: true})
+	assert.Error(t, err)
+}
@@ -0,0 +1,105 @@
+package vault
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/ossign/ossign/pkg/sigerr"
+)
+
+// A minimal client for the Vault HTTP API
+type client struct {
+	address   string
+	namespace string
+	token     string
+	http      *http.Client
+}
+
+// Send a request to the API and decode the "data" (or "auth") part of the
+// response into out
+func (c *client) do(ctx context.Context, method, path string, body, out any) error {
+	var reqBody io.Reader
+	if body != nil {
+		blob, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(blob)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(c.address, "/")+"/v1/"+strings.TrimPrefix(path, "/"), reqBody)
+	if err != nil {
+		return sigerr.ConfigError{Err: fmt.Errorf("creating Vault request: %w", err)}
+	}
+	if c.token != "" {
+		req.Header.Set("X-Vault-Token", c.token)
+	}
+	if c.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", c.namespace)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return sigerr.RemoteSigningError{Err: fmt.Errorf("%s %s: %w", method, path, err)}
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Data   json.RawMessage `json:"data"`
+		Auth   json.RawMessage `json:"auth"`
+		Errors []string        `json:"errors"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil && err != io.EOF {
+		return sigerr.RemoteSigningError{Err: fmt.Errorf("%s %s: decoding response: %w", method, path, err)}
+	}
+
+	if resp.StatusCode >= 300 {
+		err := fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, strings.Join(result.Errors, "; "))
+		if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+			return sigerr.CredentialError{Err: err}
+		}
+		return sigerr.RemoteSigningError{Err: err}
+	}
+
+	if out == nil {
+		return nil
+	}
+	raw := result.Data
+	if len(result.Auth) > 0 && string(result.Auth) != "null" {
+		raw = result.Auth
+	}
+	if err := json.Unmarshal(raw, out); err != nil {
+		return sigerr.RemoteSigningError{Err: fmt.Errorf("%s %s: decoding response: %w", method, path, err)}
+	}
+	return nil
+}
+
+// Exchange an AppRole role and secret ID for a token
+func (c *client) loginAppRole(ctx context.Context, mount, roleId, secretId string) error {
+	if mount == "" {
+		mount = "approle"
+	}
+	var auth struct {
+		ClientToken string `json:"client_token"`
+	}
+	err := c.do(ctx, http.MethodPost, "auth/"+strings.Trim(mount, "/")+"/login", map[string]string{
+		"role_id":   roleId,
+		"secret_id": secretId,
+	}, &auth)
+	if err != nil {
+		return fmt.Errorf("logging in with AppRole: %w", err)
+	}
+	if auth.ClientToken == "" {
+		return sigerr.CredentialError{Err: fmt.Errorf("AppRole login returned no token")}
+	}
+	c.token = auth.ClientToken
+	return nil
+}
@@ -0,0 +1,26 @@
+package vault
+
+// Options select the Vault server, the Transit key and the certificate chain
+type Options struct {
+	// Address of the Vault server, e.g. https://vault.example.com:8200
+	Address string
+	// Enterprise namespace, if any
+	Namespace string
+	// Mount path of the Transit secrets engine (default "transit")
+	Mount string
+	// Name of the Transit key and the version to sign with (default latest)
+	KeyName    string
+	KeyVersion int
+
+	// Authenticate with a token, or with AppRole when RoleId is set
+	Token        string
+	RoleId       string
+	SecretId     string
+	AppRoleMount string
+
+	// The certificate chain is read from a PEM file or from a KV secret. The
+	// path is the full API path, e.g. "secret/data/codesign" for KV v2.
+	CertificateFile    string
+	CertificateKvPath  string
+	CertificateKvField string
+}
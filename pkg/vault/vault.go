@@ -0,0 +1,254 @@
+package vault
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ossign/ossign/pkg/internal/leaf"
+	"github.com/ossign/ossign/pkg/sigerr"
+	"github.com/sassoftware/relic/v8/config"
+	"github.com/sassoftware/relic/v8/lib/certloader"
+	"github.com/sassoftware/relic/v8/lib/pkcs9"
+)
+
+type VaultTransitKey struct {
+	kconf   *config.KeyConfig
+	cli     *client
+	mount   string
+	name    string
+	version int
+	pub     crypto.PublicKey
+	id      []byte
+}
+
+func (k *VaultTransitKey) Config() *config.KeyConfig { return k.kconf }
+func (k *VaultTransitKey) Certificate() []byte       { return nil }
+func (k *VaultTransitKey) GetID() []byte             { return k.id }
+func (k *VaultTransitKey) Public() crypto.PublicKey  { return k.pub }
+func (k *VaultTransitKey) ImportCertificate(cert *x509.Certificate) error {
+	return fmt.Errorf("importing certificate not supported for VaultTransitKey")
+}
+
+// Each signature is a separate HTTP request
+func (k *VaultTransitKey) SignsConcurrently() bool { return true }
+
+func (k *VaultTransitKey) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return k.SignContext(context.Background(), digest, opts)
+}
+
+// Sign a digest with the Transit sign endpoint. The digest is sent prehashed
+// and ECDSA signatures are requested in ASN.1 form.
+func (k *VaultTransitKey) SignContext(ctx context.Context, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	hashAlg, err := hashAlgorithm(opts.HashFunc())
+	if err != nil {
+		return nil, err
+	}
+
+	req := map[string]any{
+		"input":                base64.StdEncoding.EncodeToString(digest),
+		"prehashed":            true,
+		"hash_algorithm":       hashAlg,
+		"key_version":          k.version,
+		"marshaling_algorithm": "asn1",
+	}
+	switch k.pub.(type) {
+	case *rsa.PublicKey:
+		if pss, ok := opts.(*rsa.PSSOptions); ok {
+			req["signature_algorithm"] = "pss"
+			switch pss.SaltLength {
+			case rsa.PSSSaltLengthAuto:
+				req["salt_length"] = "auto"
+			case rsa.PSSSaltLengthEqualsHash:
+				req["salt_length"] = "hash"
+			default:
+				req["salt_length"] = strconv.Itoa(pss.SaltLength)
+			}
+		} else {
+			req["signature_algorithm"] = "pkcs1v15"
+		}
+	case *ecdsa.PublicKey:
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", k.pub)
+	}
+
+	var resp struct {
+		Signature string `json:"signature"`
+	}
+	if err := k.cli.do(ctx, http.MethodPost, k.mount+"/sign/"+k.name, req, &resp); err != nil {
+		return nil, fmt.Errorf("signing digest: %w", err)
+	}
+
+	// signatures look like vault:v1:<base64>
+	parts := strings.SplitN(resp.Signature, ":", 3)
+	if len(parts) != 3 {
+		return nil, sigerr.RemoteSigningError{Err: fmt.Errorf("unexpected signature format %q", resp.Signature)}
+	}
+	sig, err := base64.StdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, sigerr.RemoteSigningError{Err: fmt.Errorf("decoding signature: %w", err)}
+	}
+	return sig, nil
+}
+
+func hashAlgorithm(hash crypto.Hash) (string, error) {
+	switch hash {
+	case crypto.SHA1:
+		return "sha1", nil
+	case crypto.SHA256:
+		return "sha2-256", nil
+	case crypto.SHA384:
+		return "sha2-384", nil
+	case crypto.SHA512:
+		return "sha2-512", nil
+	default:
+		return "", fmt.Errorf("unsupported digest algorithm %s", hash)
+	}
+}
+
+func NewVaultTransitKey(opts Options, ctx context.Context, timestamper pkcs9.Timestamper) (*certloader.Certificate, error) {
+	if opts.Address == "" {
+		return nil, sigerr.ConfigError{Err: errors.New("no Vault address configured")}
+	}
+	if opts.KeyName == "" {
+		return nil, sigerr.ConfigError{Err: errors.New("no Transit key name configured")}
+	}
+	mount := strings.Trim(opts.Mount, "/")
+	if mount == "" {
+		mount = "transit"
+	}
+
+	cli := &client{
+		address:   opts.Address,
+		namespace: opts.Namespace,
+		token:     opts.Token,
+		http:      http.DefaultClient,
+	}
+	if opts.RoleId != "" {
+		if err := cli.loginAppRole(ctx, opts.AppRoleMount, opts.RoleId, opts.SecretId); err != nil {
+			return nil, err
+		}
+	} else if opts.Token == "" {
+		return nil, sigerr.ConfigError{Err: errors.New("no Vault token or AppRole configured")}
+	}
+
+	key := &VaultTransitKey{
+		kconf: &config.KeyConfig{ID: opts.KeyName, Token: "vaultTransit"},
+		cli:   cli,
+		mount: mount,
+		name:  opts.KeyName,
+	}
+	if err := key.load(ctx, opts.KeyVersion); err != nil {
+		return nil, err
+	}
+
+	certs, err := key.certificates(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &certloader.Certificate{
+		Leaf:         certs[0],
+		Certificates: certs,
+		PrivateKey:   key,
+		KeyName:      opts.KeyName,
+		Timestamper:  timestamper,
+	}, nil
+}
+
+// Read the public key of the requested key version
+func (k *VaultTransitKey) load(ctx context.Context, version int) error {
+	var info struct {
+		Type          string `json:"type"`
+		LatestVersion int    `json:"latest_version"`
+		Keys          map[string]struct {
+			PublicKey string `json:"public_key"`
+		} `json:"keys"`
+	}
+	if err := k.cli.do(ctx, http.MethodGet, k.mount+"/keys/"+k.name, nil, &info); err != nil {
+		return fmt.Errorf("reading Transit key: %w", err)
+	}
+	if !strings.HasPrefix(info.Type, "rsa-") && !strings.HasPrefix(info.Type, "ecdsa-") {
+		return sigerr.ConfigError{Err: fmt.Errorf("Transit key %s has unsupported type %q", k.name, info.Type)}
+	}
+
+	if version == 0 {
+		version = info.LatestVersion
+	}
+	entry, ok := info.Keys[strconv.Itoa(version)]
+	if !ok {
+		return sigerr.ConfigError{Err: fmt.Errorf("Transit key %s has no version %d", k.name, version)}
+	}
+	block, _ := pem.Decode([]byte(entry.PublicKey))
+	if block == nil {
+		return sigerr.RemoteSigningError{Err: fmt.Errorf("Transit key %s version %d has no public key", k.name, version)}
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return sigerr.RemoteSigningError{Err: fmt.Errorf("parsing public key: %w", err)}
+	}
+
+	k.pub = pub
+	k.version = version
+	k.id = []byte(fmt.Sprintf("%s/%d", k.name, version))
+	return nil
+}
+
+// Load the certificate chain from a file or KV secret, with the certificate
+// of the Transit key first
+func (k *VaultTransitKey) certificates(ctx context.Context, opts Options) ([]*x509.Certificate, error) {
+	var blob []byte
+	switch {
+	case opts.CertificateFile != "":
+		var err error
+		if blob, err = os.ReadFile(opts.CertificateFile); err != nil {
+			return nil, sigerr.ConfigError{Err: fmt.Errorf("reading certificate file: %w", err)}
+		}
+	case opts.CertificateKvPath != "":
+		var err error
+		if blob, err = k.readKvCertificate(ctx, opts.CertificateKvPath, opts.CertificateKvField); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, sigerr.ConfigError{Err: errors.New("no certificate file or KV path configured for the Transit key")}
+	}
+
+	certs, err := certloader.ParseX509Certificates(blob)
+	if err != nil {
+		return nil, sigerr.ConfigError{Err: fmt.Errorf("parsing certificate: %w", err)}
+	}
+	return leaf.First(certs, k.pub, "Transit key")
+}
+
+// Read PEM certificates from a field of a KV v1 or v2 secret
+func (k *VaultTransitKey) readKvCertificate(ctx context.Context, path, field string) ([]byte, error) {
+	if field == "" {
+		field = "certificate"
+	}
+	var secret map[string]any
+	if err := k.cli.do(ctx, http.MethodGet, path, nil, &secret); err != nil {
+		return nil, fmt.Errorf("reading certificate secret: %w", err)
+	}
+	// KV v2 nests the secret in another data object
+	if nested, ok := secret["data"].(map[string]any); ok {
+		if _, ok := secret["metadata"]; ok {
+			secret = nested
+		}
+	}
+	value, ok := secret[field].(string)
+	if !ok {
+		return nil, sigerr.ConfigError{Err: fmt.Errorf("secret %s has no field %q", path, field)}
+	}
+	return []byte(value), nil
+}
@@ -0,0 +1,187 @@
+package vault
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ossign/ossign/pkg/sigerr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// A fake Vault server with one Transit key and one KV v2 secret holding its
+// certificate
+type fakeVault struct {
+	key      crypto.Signer
+	keyType  string
+	certPEM  string
+	token    string
+	roleId   string
+	secretId string
+}
+
+func (f *fakeVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	reply := func(status int, body any) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(body)
+	}
+
+	if r.URL.Path == "/v1/auth/approle/login" {
+		var req map[string]string
+		json.NewDecoder(r.Body).Decode(&req)
+		if req["role_id"] != f.roleId || req["secret_id"] != f.secretId {
+			reply(http.StatusBadRequest, map[string]any{"errors": []string{"invalid role or secret ID"}})
+			return
+		}
+		reply(http.StatusOK, map[string]any{"auth": map[string]string{"client_token": f.token}})
+		return
+	}
+	if r.Header.Get("X-Vault-Token") != f.token {
+		reply(http.StatusForbidden, map[string]any{"errors": []string{"permission denied"}})
+		return
+	}
+
+	switch r.URL.Path {
+	case "/v1/transit/keys/codesign":
+		der, _ := x509.MarshalPKIXPublicKey(f.key.Public())
+		reply(http.StatusOK, map[string]any{"data": map[string]any{
+			"type":           f.keyType,
+			"latest_version": 1,
+			"keys": map[string]any{
+				"1": map[string]string{"public_key": string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))},
+			},
+		}})
+	case "/v1/transit/sign/codesign":
+		var req struct {
+			Input              string `json:"input"`
+			Prehashed          bool   `json:"prehashed"`
+			HashAlgorithm      string `json:"hash_algorithm"`
+			SignatureAlgorithm string `json:"signature_algorithm"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		digest, _ := base64.StdEncoding.DecodeString(req.Input)
+		if !req.Prehashed || req.HashAlgorithm != "sha2-256" {
+			reply(http.StatusBadRequest, map[string]any{"errors": []string{"unexpected request"}})
+			return
+		}
+		var opts crypto.SignerOpts = crypto.SHA256
+		if req.SignatureAlgorithm == "pss" {
+			opts = &rsa.PSSOptions{Hash: crypto.SHA256, SaltLength: rsa.PSSSaltLengthEqualsHash}
+		}
+		sig, err := f.key.Sign(rand.Reader, digest, opts)
+		if err != nil {
+			reply(http.StatusInternalServerError, map[string]any{"errors": []string{err.Error()}})
+			return
+		}
+		reply(http.StatusOK, map[string]any{"data": map[string]string{
+			"signature": "vault:v1:" + base64.StdEncoding.EncodeToString(sig),
+		}})
+	case "/v1/secret/data/codesign":
+		reply(http.StatusOK, map[string]any{"data": map[string]any{
+			"data":     map[string]string{"certificate": f.certPEM},
+			"metadata": map[string]any{"version": 1},
+		}})
+	default:
+		reply(http.StatusNotFound, map[string]any{"errors": []string{}})
+	}
+}
+
+func newFakeVault(t *testing.T, key crypto.Signer, keyType string) *fakeVault {
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "vault signer"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	require.NoError(t, err)
+	return &fakeVault{
+		key:      key,
+		keyType:  keyType,
+		certPEM:  string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		token:    "s.test",
+		roleId:   "role",
+		secretId: "secret",
+	}
+}
+
+func TestVaultTransitRSA(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	fake := newFakeVault(t, rsaKey, "rsa-2048")
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	cert, err := NewVaultTransitKey(Options{
+		Address:           srv.URL,
+		KeyName:           "codesign",
+		RoleId:            "role",
+		SecretId:          "secret",
+		CertificateKvPath: "secret/data/codesign",
+	}, context.Background(), nil)
+	require.NoError(t, err)
+	assert.True(t, rsaKey.PublicKey.Equal(cert.Leaf.PublicKey))
+
+	digest := sha256.Sum256([]byte("hello"))
+	sig, err := cert.Signer().Sign(rand.Reader, digest[:], crypto.SHA256)
+	require.NoError(t, err)
+	assert.NoError(t, rsa.VerifyPKCS1v15(&rsaKey.PublicKey, crypto.SHA256, digest[:], sig))
+
+	pss := &rsa.PSSOptions{Hash: crypto.SHA256, SaltLength: rsa.PSSSaltLengthEqualsHash}
+	sig, err = cert.Signer().Sign(rand.Reader, digest[:], pss)
+	require.NoError(t, err)
+	assert.NoError(t, rsa.VerifyPSS(&rsaKey.PublicKey, crypto.SHA256, digest[:], sig, pss))
+}
+
+func TestVaultTransitECDSA(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	fake := newFakeVault(t, ecKey, "ecdsa-p256")
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	cert, err := NewVaultTransitKey(Options{
+		Address:           srv.URL,
+		KeyName:           "codesign",
+		Token:             "s.test",
+		CertificateKvPath: "secret/data/codesign",
+	}, context.Background(), nil)
+	require.NoError(t, err)
+
+	digest := sha256.Sum256([]byte("hello"))
+	sig, err := cert.Signer().Sign(rand.Reader, digest[:], crypto.SHA256)
+	require.NoError(t, err)
+	assert.True(t, ecdsa.VerifyASN1(&ecKey.PublicKey, digest[:], sig))
+}
+
+func TestVaultTransitBadToken(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	srv := httptest.NewServer(newFakeVault(t, ecKey, "ecdsa-p256"))
+	defer srv.Close()
+
+	_, err = NewVaultTransitKey(Options{
+		Address:           srv.URL,
+		KeyName:           "codesign",
+		Token:             "s.wrong",
+		CertificateKvPath: "secret/data/codesign",
+	}, context.Background(), nil)
+	assert.True(t, errors.As(err, &sigerr.CredentialError{}))
+}
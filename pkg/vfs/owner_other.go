@@ -0,0 +1,15 @@
+//go:build !unix && !js && !wasm
+
+package vfs
+
+import "os"
+
+// Ownership is inherited from the directory on this platform
+func copyOwner(name string, orig os.FileInfo) error {
+	return nil
+}
+
+// Directories cannot be synced on this platform
+func syncDir(dir string) error {
+	return nil
+}
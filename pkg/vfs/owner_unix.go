@@ -0,0 +1,30 @@
+//go:build unix
+
+package vfs
+
+import (
+	"os"
+	"syscall"
+)
+
+// Give a file the same owner and group as the original
+func copyOwner(name string, orig os.FileInfo) error {
+	st, ok := orig.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	if int(st.Uid) == os.Geteuid() && int(st.Gid) == os.Getegid() {
+		return nil
+	}
+	return os.Lchown(name, int(st.Uid), int(st.Gid))
+}
+
+// Flush a directory entry change such as a rename to disk
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
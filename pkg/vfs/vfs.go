@@ -3,7 +3,9 @@
 package vfs
 
 import (
+	"fmt"
 	"os"
+	"path/filepath"
 
 	"github.com/sassoftware/relic/v8/lib/vfs"
 )
@@ -17,10 +19,23 @@ func ReadFromFile(filename string) (*vfs.File, error) {
 }
 
 func WriteToFile(data *vfs.File) error {
-	data.Seek(0, 0)
+	if data == nil {
+		return os.ErrInvalid
+	}
 
-	// In a real implementation, you would send this data to a server or process it.
-	// Here we just simulate a successful post by returning nil.
+	allBytes := data.Bytes()
+	if len(allBytes) == 0 {
+		return os.ErrInvalid
+	}
+
+	return os.WriteFile(data.Name(), allBytes, 0644)
+}
+
+// Replace an existing file with new contents. The data is written to a
+// temporary file next to the target, synced to disk and renamed over it, so an
+// interrupted write never leaves a partial file behind. The mode, ownership
+// and modification time of the original file are preserved.
+func ReplaceFile(data *vfs.File) error {
 	if data == nil {
 		return os.ErrInvalid
 	}
@@ -30,8 +45,49 @@ func WriteToFile(data *vfs.File) error {
 		return os.ErrInvalid
 	}
 
-	os.WriteFile(data.Name(), allBytes, 0644)
+	target := data.Name()
+	info, err := os.Stat(target)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(target), "."+filepath.Base(target)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("creating temporary file: %w", err)
+	}
+	tmpName := tmp.Name()
+	committed := false
+	defer func() {
+		if !committed {
+			tmp.Close()
+			os.Remove(tmpName)
+		}
+	}()
+
+	if _, err := tmp.Write(allBytes); err != nil {
+		return fmt.Errorf("writing temporary file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return fmt.Errorf("syncing temporary file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("closing temporary file: %w", err)
+	}
+
+	if err := os.Chmod(tmpName, info.Mode().Perm()); err != nil {
+		return fmt.Errorf("setting file mode: %w", err)
+	}
+	if err := copyOwner(tmpName, info); err != nil {
+		return fmt.Errorf("setting file owner: %w", err)
+	}
+	if err := os.Chtimes(tmpName, info.ModTime(), info.ModTime()); err != nil {
+		return fmt.Errorf("setting file times: %w", err)
+	}
+
+	if err := os.Rename(tmpName, target); err != nil {
+		return fmt.Errorf("replacing %s: %w", target, err)
+	}
+	committed = true
 
-	// Simulate successful post
-	return nil
+	return syncDir(filepath.Dir(target))
 }
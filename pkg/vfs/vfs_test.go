@@ -0,0 +1,43 @@
+//go:build !js && !wasm
+
+package vfs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sassoftware/relic/v8/lib/vfs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplaceFile(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "setup.exe")
+	require.NoError(t, os.WriteFile(target, []byte("unsigned"), 0755))
+	mtime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	require.NoError(t, os.Chtimes(target, mtime, mtime))
+
+	require.NoError(t, ReplaceFile(vfs.New([]byte("signed"), target)))
+
+	content, err := os.ReadFile(target)
+	require.NoError(t, err)
+	assert.Equal(t, "signed", string(content))
+
+	info, err := os.Stat(target)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0755), info.Mode().Perm())
+	assert.True(t, info.ModTime().Equal(mtime))
+
+	// no temporary files are left behind
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestReplaceFileMissing(t *testing.T) {
+	err := ReplaceFile(vfs.New([]byte("signed"), filepath.Join(t.TempDir(), "missing.exe")))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
@@ -50,3 +50,8 @@ func WriteToFile(data *vfs.File) error {
 
 	return nil
 }
+
+// Files are written through the host, which is responsible for replacing them
+func ReplaceFile(data *vfs.File) error {
+	return WriteToFile(data)
+}